@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/rithvikp/speeddial/state"
+	"github.com/rithvikp/speeddial/term"
+)
+
+// pickerActions returns the actions that can be performed on the selected command while
+// searching.
+func pickerActions(c *state.Container) []term.Action[*state.Command] {
+	return []term.Action[*state.Command]{
+		{
+			Key: term.KeyCtrlD,
+			Confirm: func(item term.ListItem[*state.Command]) string {
+				return fmt.Sprintf("Delete `%s`?", item.Raw.Invocation)
+			},
+			Run: func(item term.ListItem[*state.Command]) (term.ActionResult, error) {
+				if err := c.DeleteCommand(item.Raw); err != nil {
+					return term.ActionResult{}, fmt.Errorf("unable to delete the command: %v", err)
+				}
+				return term.ActionResult{Refresh: true, Message: fmt.Sprintf("Deleted `%s`", item.Raw.Invocation)}, nil
+			},
+		},
+		{
+			Key:     term.KeyCtrlO,
+			Suspend: true,
+			Run: func(item term.ListItem[*state.Command]) (term.ActionResult, error) {
+				edited, err := editInEditor(item.Raw)
+				if err != nil {
+					return term.ActionResult{}, fmt.Errorf("unable to edit the command: %v", err)
+				}
+
+				if err := c.UpdateCommand(item.Raw, edited.invocation, edited.description, edited.tags); err != nil {
+					return term.ActionResult{}, fmt.Errorf("unable to update the command: %v", err)
+				}
+				return term.ActionResult{Refresh: true, Message: fmt.Sprintf("Updated `%s`", item.Raw.Invocation)}, nil
+			},
+		},
+		{
+			Key: term.KeyCtrlY,
+			Run: func(item term.ListItem[*state.Command]) (term.ActionResult, error) {
+				copyToClipboard(item.Raw.Invocation)
+				return term.ActionResult{Message: fmt.Sprintf("Copied `%s` to the clipboard", item.Raw.Invocation)}, nil
+			},
+		},
+		{
+			Key:     term.KeyCtrlX,
+			Suspend: true,
+			Run: func(item term.ListItem[*state.Command]) (term.ActionResult, error) {
+				if err := execute(c, item.Raw); err == term.ErrUserQuit {
+					return term.ActionResult{}, nil
+				} else if err != nil {
+					return term.ActionResult{}, err
+				}
+				return term.ActionResult{Close: true}, nil
+			},
+		},
+	}
+}
+
+// copyToClipboard copies the text to the system clipboard with the OSC 52 escape sequence, which
+// is supported by most terminals (including over SSH) without needing any external tools.
+func copyToClipboard(text string) {
+	fmt.Fprintf(os.Stderr, "\033]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+}
+
+// execute runs the command in the user's shell, filling in its placeholders first. The command's
+// output is sent to stderr since stdout is used to prefill the next prompt. term.ErrUserQuit is
+// returned, without running the command, if the user quits the placeholder form.
+func execute(c *state.Container, command *state.Command) error {
+	invocation := command.Invocation
+	if placeholders := command.Placeholders(); len(placeholders) > 0 {
+		var err error
+		invocation, err = fillPlaceholders(c, command, placeholders)
+		if err == term.ErrUserQuit {
+			return err
+		} else if err != nil {
+			return fmt.Errorf("unable to fill in the placeholders: %v", err)
+		}
+	}
+	command.RecordUse()
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "sh"
+	}
+
+	fmt.Fprintf(os.Stderr, "$ %s\n", invocation)
+	cmd := exec.Command(shell, "-c", invocation)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	// A non-zero exit status has already been reported by the command itself
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); err != nil && !errors.As(err, &exitErr) {
+		return fmt.Errorf("unable to run the command: %v", err)
+	}
+	return nil
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"github.com/pterm/pterm"
+	"github.com/rithvikp/speeddial/state"
+	"github.com/rithvikp/speeddial/term"
 	"github.com/spf13/cobra"
 )
 
@@ -13,30 +15,93 @@ var (
 	addCmd = &cobra.Command{
 		Use:   "add",
 		Short: "Add a new command to speeddial",
-		Args:  cobra.ExactArgs(1),
+		Long: `Add the given command to speeddial. If no command is given, the previous command run in the
+shell is added instead.`,
+		Args: cobra.MaximumNArgs(1),
 
 		Run: runAdd,
 	}
+
+	addTagsArg []string
+	addToArg   string
 )
 
+func init() {
+	addCmd.Flags().StringSliceVarP(&addTagsArg, "tag", "t", nil, "Tag the command (can be repeated or comma-separated)")
+	addCmd.Flags().StringVar(&addToArg, "to", "", "The source to add the command to (defaults to the configured default source)")
+}
+
 func runAdd(cmd *cobra.Command, args []string) {
 	c := setup()
 	defer dump(c)
 
-	command := args[0]
-
-	printCommand := os.Getenv(addPrintCommandEnvVar) != ""
-	if printCommand {
+	var command string
+	if len(args) > 0 {
+		command = args[0]
+	} else {
+		// The shell wrapper provides the previous command
+		command = os.Getenv(previousCommandEnvVar)
+		if command == "" {
+			fmt.Fprintln(os.Stderr, "Unable to determine the previous command: please specify the command to add")
+			os.Exit(1) // nolint:gocritic // It is ok that the deferred dump does not run since there was no state update.
+		}
 		fmt.Fprintf(os.Stderr, "Adding command: %s\n", pterm.Bold.Sprint(command))
 	}
 
+	if existing := c.FindDuplicate(command); existing != nil && !handleDuplicate(c, existing) {
+		return
+	}
+
+	desc := promptDescription()
+
+	var err error
+	if addToArg != "" {
+		err = c.NewCommandIn(addToArg, command, desc, addTagsArg)
+	} else {
+		err = c.NewCommand(command, desc, addTagsArg)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to add the new command: %v\n", err)
+	}
+}
+
+func promptDescription() string {
 	scanner := bufio.NewScanner(os.Stdin)
 	fmt.Fprint(os.Stderr, "Please input a description if desired: ")
 	scanner.Scan()
-	desc := scanner.Text()
+	return scanner.Text()
+}
+
+// handleDuplicate shows the existing command that matches the one being added and lets the user
+// decide what to do with it. It returns true if the new command should still be added.
+func handleDuplicate(c *state.Container, existing *state.Command) bool {
+	fmt.Fprintf(os.Stderr, "A matching command already exists: %s\n", describeCommand(existing))
+
+	options := []term.ChoiceOption{{Key: 'u', Label: "update its description"}}
+	if len(addTagsArg) > 0 {
+		options = append(options, term.ChoiceOption{Key: 'm', Label: "merge the tags into it"})
+	}
+	options = append(options, term.ChoiceOption{Key: 'k', Label: "keep both"}, term.ChoiceOption{Key: 'c', Label: "cancel"})
+
+	i, err := term.Choice("What would you like to do?", options)
+	if err == term.ErrUserQuit {
+		return false
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to choose how to handle the duplicate: %v\n", err)
+		return false
+	}
+
+	switch options[i].Key {
+	case 'u':
+		err = c.UpdateCommand(existing, existing.Invocation, promptDescription(), existing.Tags)
+	case 'm':
+		err = c.MergeTags(existing, addTagsArg)
+	case 'k':
+		return true
+	}
 
-	err := c.NewCommand(command, desc)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Unable to add the new command: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Unable to update the existing command: %v\n", err)
 	}
+	return false
 }
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rithvikp/speeddial/term"
+	"github.com/spf13/cobra"
+)
+
+// maxDedupeChoices is the number of commands in a group that can be chosen with a single digit.
+const maxDedupeChoices = 9
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Find and merge duplicate commands",
+	Long: `Find commands that are the same after ignoring differences in whitespace and quoting. For each
+group of duplicates, press the number of the command to keep and the others are merged into it:
+their tags and usage are combined, and the kept command takes a description from them if it does
+not have one. Press "s" to skip a group or "q" to stop.`,
+	Args: cobra.NoArgs,
+
+	Run: runDedupe,
+}
+
+func runDedupe(cmd *cobra.Command, args []string) {
+	c := setup()
+	defer dump(c)
+
+	groups := c.Duplicates()
+	if len(groups) == 0 {
+		fmt.Fprintln(os.Stderr, "There are no duplicate commands")
+		return
+	}
+
+	merged := 0
+	for i, group := range groups {
+		fmt.Fprintf(os.Stderr, "Duplicate group %d of %d:\n", i+1, len(groups))
+
+		var options []term.ChoiceOption
+		for j, command := range group {
+			fmt.Fprintf(os.Stderr, "  %d. %s\n", j+1, describeCommand(command))
+			if j < maxDedupeChoices {
+				options = append(options, term.ChoiceOption{Key: rune('1' + j), Label: fmt.Sprintf("keep %d", j+1)})
+			}
+		}
+		options = append(options, term.ChoiceOption{Key: 's', Label: "skip"}, term.ChoiceOption{Key: 'q', Label: "quit"})
+
+		k, err := term.Choice("Which command should be kept?", options)
+		if err == term.ErrUserQuit || (err == nil && options[k].Key == 'q') {
+			break
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to choose the command to keep: %v\n", err)
+			break
+		} else if options[k].Key == 's' {
+			continue
+		}
+
+		if err := c.MergeCommands(group[k], group); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to merge the duplicates: %v\n", err)
+			continue
+		}
+		merged++
+	}
+
+	fmt.Fprintf(os.Stderr, "Merged %d group(s) of duplicates\n", merged)
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/rithvikp/speeddial/state"
+	"github.com/rithvikp/speeddial/term"
+	"github.com/spf13/cobra"
+)
+
+const (
+	editInvocationKey  = "invocation"
+	editDescriptionKey = "description"
+	editTagsKey        = "tags"
+)
+
+var (
+	editCmd = &cobra.Command{
+		Use:   "edit",
+		Short: "Edit a command in speeddial",
+		Long: `Use the search menu and the arrow keys to select an entry and then press "enter" to edit it.
+
+By default, the command is edited in an inline form. With --editor, the command is instead opened
+in $VISUAL or $EDITOR as a temporary file.`,
+
+		Run: runEdit,
+	}
+
+	editRegexArg  bool
+	editEditorArg bool
+)
+
+func init() {
+	editCmd.Flags().BoolVarP(&editRegexArg, "regex", "r", false, "Use regex instead of fuzzy search")
+	editCmd.Flags().BoolVarP(&editEditorArg, "editor", "e", false, "Edit the command in $VISUAL or $EDITOR instead of inline")
+}
+
+// editedCommand holds the user-editable fields of a command.
+type editedCommand struct {
+	invocation  string
+	description string
+	tags        []string
+}
+
+func runEdit(cmd *cobra.Command, args []string) {
+	c := setup()
+	defer dump(c)
+
+	command := search(c, state.SearchOptions{Mode: searchMode(editRegexArg)}, "")
+
+	var edited *editedCommand
+	var err error
+	if editEditorArg {
+		edited, err = editInEditor(command)
+	} else {
+		edited, err = editInline(command)
+	}
+
+	if err == term.ErrUserQuit {
+		os.Exit(0) // nolint:gocritic // It is ok that the deferred dump does not run since there was no state update.
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to edit the command: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = c.UpdateCommand(command, edited.invocation, edited.description, edited.tags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to update the command: %v\n", err)
+	}
+}
+
+func editInline(command *state.Command) (*editedCommand, error) {
+	values, err := term.Form("Edit the command:", []term.FormField{
+		{Label: editInvocationKey, Value: command.Invocation},
+		{Label: editDescriptionKey, Value: command.Description},
+		{Label: editTagsKey, Value: strings.Join(command.Tags, ", ")},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &editedCommand{
+		invocation:  values[0],
+		description: values[1],
+		tags:        strings.Split(values[2], ","),
+	}, nil
+}
+
+// editInEditor writes the command out to a temporary file, opens it in the user's editor, and then
+// parses the edited file once the editor exits.
+func editInEditor(command *state.Command) (*editedCommand, error) {
+	f, err := os.CreateTemp("", "speeddial-*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create a temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(formatEditedCommand(command))
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to write to the temporary file: %v", err)
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// The editor may have been specified with arguments (e.g. "code --wait")
+	editorArgs := strings.Fields(editor)
+	ed := exec.Command(editorArgs[0], append(editorArgs[1:], f.Name())...)
+	ed.Stdin = os.Stdin
+	ed.Stdout = os.Stderr
+	ed.Stderr = os.Stderr
+	if err := ed.Run(); err != nil {
+		return nil, fmt.Errorf("unable to run the editor %q: %v", editor, err)
+	}
+
+	f, err = os.Open(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the edited file: %v", err)
+	}
+	defer f.Close()
+
+	return parseEditedCommand(bufio.NewScanner(f))
+}
+
+// formatEditedCommand returns the contents of the file that editInEditor opens for the command.
+func formatEditedCommand(command *state.Command) string {
+	return fmt.Sprintf(`# Edit the command below and then save and close the file. Lines starting with '#' are ignored
+# and tags are separated by commas. Everything after the invocation line is the invocation, which
+# can span multiple lines.
+%s: %s
+%s: %s
+%s:
+%s
+`, editDescriptionKey, command.Description, editTagsKey, strings.Join(command.Tags, ", "), editInvocationKey, command.Invocation)
+}
+
+// parseEditedCommand parses a file written by editInEditor. Each field is on a "key: value" line,
+// except that the invocation takes up the rest of the file (starting with any value on its own
+// line), so that it can span multiple lines.
+func parseEditedCommand(scanner *bufio.Scanner) (*editedCommand, error) {
+	var edited editedCommand
+	seen := make(map[string]bool)
+	// The lines of the invocation, which are kept as-is once the invocation has started
+	var invocation []string
+	inInvocation := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inInvocation {
+			invocation = append(invocation, line)
+			continue
+		}
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("line %q is not of the form \"key: value\"", line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if seen[key] {
+			return nil, fmt.Errorf("%q was specified multiple times", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case editInvocationKey:
+			inInvocation = true
+			invocation = append(invocation, value)
+		case editDescriptionKey:
+			edited.description = value
+		case editTagsKey:
+			edited.tags = strings.Split(value, ",")
+		default:
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	edited.invocation = strings.TrimSpace(strings.Join(invocation, "\n"))
+
+	if edited.invocation == "" {
+		return nil, errors.New("the invocation cannot be empty")
+	}
+
+	return &edited, nil
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rithvikp/speeddial/state"
+)
+
+func TestParseEditedCommand(t *testing.T) {
+	tests := []struct {
+		msg     string
+		text    string
+		want    *editedCommand
+		wantErr bool
+	}{
+		{
+			msg:  "all keys",
+			text: "description: Show the status\ntags: git,vcs\ninvocation: git status\n",
+			want: &editedCommand{invocation: "git status", description: "Show the status", tags: []string{"git", "vcs"}},
+		},
+		{
+			msg:  "comments and blank lines are ignored",
+			text: "# Edit the command below\n\ndescription: List files\n   \n# tags: ignored\ninvocation: ls -la\n",
+			want: &editedCommand{invocation: "ls -la", description: "List files"},
+		},
+		{
+			msg:  "the invocation on the lines after its key",
+			text: "description: List files\ninvocation:\nls -la\n\n",
+			want: &editedCommand{invocation: "ls -la", description: "List files"},
+		},
+		{
+			msg:  "a multi-line invocation is kept as-is",
+			text: "description: Vet each file\ninvocation:\nfor f in *.go; do\n  # vet: one file\n\n  go vet \"$f\"\ndone\n",
+			want: &editedCommand{invocation: "for f in *.go; do\n  # vet: one file\n\n  go vet \"$f\"\ndone", description: "Vet each file"},
+		},
+		{
+			msg:  "values may contain colons",
+			text: "invocation: echo a:b\n",
+			want: &editedCommand{invocation: "echo a:b"},
+		},
+		{
+			msg:  "the tags line is split on commas without trimming",
+			text: "tags: build, ci,\ninvocation: make\n",
+			want: &editedCommand{invocation: "make", tags: []string{"build", " ci", ""}},
+		},
+		{
+			msg:  "an empty tags line",
+			text: "tags:\ninvocation: make\n",
+			want: &editedCommand{invocation: "make", tags: []string{""}},
+		},
+		{msg: "a missing invocation", text: "description: Nothing to run\n", wantErr: true},
+		{msg: "an empty invocation", text: "description: Nothing to run\ninvocation:\n\n", wantErr: true},
+		{msg: "an empty file", text: "", wantErr: true},
+		{msg: "a line without a key", text: "just text\ninvocation: ls\n", wantErr: true},
+		{msg: "an unknown key", text: "alias: l\ninvocation: ls\n", wantErr: true},
+		{msg: "a repeated key", text: "description: a\ndescription: b\ninvocation: ls\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseEditedCommand(bufio.NewScanner(strings.NewReader(tt.text)))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Expected an error when parsing %s", tt.msg)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("Unable to parse %s: %v", tt.msg, err)
+			continue
+		}
+
+		if diff := cmp.Diff(got, tt.want, cmp.AllowUnexported(editedCommand{})); diff != "" {
+			t.Errorf("Edited command for %s diff (-got, +want):\n%s", tt.msg, diff)
+		}
+	}
+}
+
+func TestEditedCommandRoundTrip(t *testing.T) {
+	command := &state.Command{
+		Invocation:  "docker run \\\n  --rm \\\n  alpine: echo hi",
+		Description: "Say hi: in a container",
+		Tags:        []string{"docker"},
+	}
+
+	got, err := parseEditedCommand(bufio.NewScanner(strings.NewReader(formatEditedCommand(command))))
+	if err != nil {
+		t.Fatalf("Unable to parse the unedited command: %v", err)
+	}
+	want := &editedCommand{invocation: command.Invocation, description: command.Description, tags: command.Tags}
+	if diff := cmp.Diff(got, want, cmp.AllowUnexported(editedCommand{})); diff != "" {
+		t.Errorf("Unedited command diff (-got, +want):\n%s", diff)
+	}
+}
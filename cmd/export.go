@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rithvikp/speeddial/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export commands in a portable format",
+		Long: `Export commands as JSON, YAML, TOML or a Markdown cheat sheet. The JSON, YAML and TOML exports
+include every field of each command and can be imported again with "speeddial import <file>".`,
+		Args: cobra.NoArgs,
+
+		Run: runExport,
+	}
+
+	exportFormatArg  string
+	exportOutputArg  string
+	exportSourceArg  string
+	exportGroupByArg string
+)
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportFormatArg, "format", "f", "", "The format to export in: json, yaml, toml or markdown (defaults to the output file's extension, or json)")
+	exportCmd.Flags().StringVarP(&exportOutputArg, "output", "o", "", "The file to write to (defaults to stdout)")
+	exportCmd.Flags().StringVarP(&exportSourceArg, "source", "s", "", "Only export commands from this source")
+	exportCmd.Flags().StringVarP(&exportGroupByArg, "group-by", "g", state.GroupByTag, "How to group commands in Markdown exports: tag or source")
+}
+
+func runExport(cmd *cobra.Command, args []string) {
+	c := setup()
+
+	format := exportFormatArg
+	if format == "" {
+		format = state.FormatFromPath(exportOutputArg)
+	}
+	if format == "" {
+		format = state.FormatJSON
+	}
+
+	var commands []*state.Command
+	for _, command := range c.List() {
+		if exportSourceArg == "" || command.Source() == exportSourceArg {
+			commands = append(commands, command)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if exportOutputArg != "" {
+		f, err := os.Create(exportOutputArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to create the output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := state.Export(w, format, exportGroupByArg, commands); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to export the commands: %v\n", err)
+		os.Exit(1) // nolint:gocritic // The partially written output file does not need to be closed.
+	}
+}
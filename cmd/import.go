@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rithvikp/speeddial/history"
+	"github.com/rithvikp/speeddial/state"
+	"github.com/rithvikp/speeddial/term"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importCmd = &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import commands into speeddial",
+		Long: `Import commands from a JSON, YAML or TOML file, such as one created by "speeddial export". The
+format is determined from the file's extension unless --format is given. Commands that have
+already been saved are skipped.
+
+Use "speeddial import history" to import commands from your shell history instead.`,
+		Args: cobra.ExactArgs(1),
+
+		Run: runImport,
+	}
+
+	importHistoryCmd = &cobra.Command{
+		Use:   "history",
+		Short: "Import commands from your shell history",
+		Long: `Search over the commands in your shell history, ordered by how often they were run. Press "tab"
+to mark the commands to import and then press "enter" to import them.
+
+Zsh, bash and fish history files are supported. By default, the shell is determined from $SHELL.`,
+		Args: cobra.NoArgs,
+
+		Run: runImportHistory,
+	}
+
+	importFormatArg string
+	importToArg     string
+
+	importHistoryShellArg    string
+	importHistoryFileArg     string
+	importHistoryMinCountArg int
+)
+
+func init() {
+	importCmd.AddCommand(importHistoryCmd)
+
+	importCmd.Flags().StringVarP(&importFormatArg, "format", "f", "", "The format of the file: json, yaml or toml")
+	importCmd.Flags().StringVar(&importToArg, "to", "", "The source to import the commands into (defaults to the configured default source)")
+
+	importHistoryCmd.Flags().StringVarP(&importHistoryShellArg, "shell", "s", "", "The shell whose history should be imported (zsh, bash or fish)")
+	importHistoryCmd.Flags().StringVar(&importHistoryFileArg, "file", "", "The history file to import (defaults to the shell's standard history file)")
+	importHistoryCmd.Flags().IntVarP(&importHistoryMinCountArg, "min-count", "m", 1, "Only show commands that were run at least this many times")
+}
+
+func runImport(cmd *cobra.Command, args []string) {
+	c := setup()
+	defer dump(c)
+
+	path := args[0]
+	format := importFormatArg
+	if format == "" {
+		format = state.FormatFromPath(path)
+	}
+	if format == "" {
+		fmt.Fprintf(os.Stderr, "Unable to determine the format of %s: please specify it with --format\n", path)
+		os.Exit(1) // nolint:gocritic // It is ok that the deferred dump does not run since there was no state update.
+	}
+
+	to := importToArg
+	if to == "" {
+		to = c.DefaultSource()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to open the file to import: %v\n", err)
+		os.Exit(1)
+	}
+	commands, entryErrs, err := state.Import(f, format)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to import %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	for _, err := range entryErrs {
+		fmt.Fprintf(os.Stderr, "Skipping an invalid %v\n", err)
+	}
+
+	existing := c.Invocations()
+	imported, skipped := 0, 0
+	for _, command := range commands {
+		if existing.Contains(command.Invocation) {
+			skipped++
+			continue
+		}
+
+		if err := c.AddCommandIn(to, command); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to import %q: %v\n", command.Invocation, err)
+			continue
+		}
+		existing.Add(command.Invocation)
+		imported++
+	}
+
+	fmt.Fprintf(os.Stderr, "Imported %d command(s), skipped %d duplicate(s) and %d invalid entries\n", imported, skipped, len(entryErrs))
+}
+
+func runImportHistory(cmd *cobra.Command, args []string) {
+	c := setup()
+	defer dump(c)
+
+	shell := importHistoryShellArg
+	if shell == "" {
+		shell = filepath.Base(os.Getenv("SHELL"))
+	}
+
+	path := importHistoryFileArg
+	if path == "" {
+		var err error
+		path, err = defaultHistoryFile(shell)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to find the history file: %v\n", err)
+			os.Exit(1) // nolint:gocritic // It is ok that the deferred dump does not run since there was no state update.
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to open the history file: %v\n", err)
+		os.Exit(1)
+	}
+	entries, err := history.Parse(shell, f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse the history file at %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	existing := c.Invocations()
+	var candidates []*history.Candidate
+	for _, cand := range history.Rank(entries) {
+		cand := cand
+		if cand.Count < importHistoryMinCountArg || isSpeeddialInvocation(cand.Command) || existing.Contains(cand.Command) {
+			continue
+		}
+		candidates = append(candidates, &cand)
+	}
+
+	if len(candidates) == 0 {
+		fmt.Fprintln(os.Stderr, "There are no new commands in your history to import")
+		os.Exit(0)
+	}
+
+	selected, err := term.ListMulti[*history.Candidate](&historyList{candidates: candidates}, listOptions(""))
+	if err == term.ErrUserQuit {
+		os.Exit(0)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to select the commands to import: %v\n", err)
+		os.Exit(1)
+	}
+
+	imported := 0
+	for _, cand := range selected {
+		// Candidates may still be duplicates of each other after normalization
+		if existing.Contains(cand.Command) {
+			continue
+		}
+
+		if err := c.NewCommand(cand.Command, "", nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to import %q: %v\n", cand.Command, err)
+			continue
+		}
+		existing.Add(cand.Command)
+		imported++
+	}
+
+	fmt.Fprintf(os.Stderr, "Imported %d command(s)\n", imported)
+}
+
+// defaultHistoryFile returns the standard location of the given shell's history file.
+func defaultHistoryFile(shell string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	switch shell {
+	case history.Zsh:
+		return filepath.Join(home, ".zsh_history"), nil
+	case history.Bash:
+		return filepath.Join(home, ".bash_history"), nil
+	case history.Fish:
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		return filepath.Join(dataHome, "fish", "fish_history"), nil
+	}
+
+	return "", fmt.Errorf("%q is not a supported shell: please specify one with --shell", shell)
+}
+
+// isSpeeddialInvocation determines whether the command runs speeddial itself, in which case it
+// should not be imported.
+func isSpeeddialInvocation(command string) bool {
+	fields := strings.Fields(command)
+	return len(fields) > 0 && (fields[0] == "spd" || fields[0] == "speeddial")
+}
+
+// historyList is a searchable view over history candidates. It conforms to the
+// term.QueryableList interface.
+type historyList struct {
+	candidates []*history.Candidate
+}
+
+// Search returns the candidates which contain the query, ignoring case.
+func (l *historyList) Search(query string) ([]term.ListItem[*history.Candidate], error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	var items []term.ListItem[*history.Candidate]
+	for _, cand := range l.candidates {
+		cmd := term.FormattedContent{Content: cand.Command}
+
+		if query != "" {
+			lower := strings.ToLower(cand.Command)
+			i := strings.Index(lower, query)
+			if i < 0 {
+				continue
+			}
+
+			// Lowercasing can change the length of some strings, in which case the offsets are not
+			// valid for the original command.
+			if len(lower) == len(cand.Command) {
+				cmd.Highlights = []term.FormattedChunk{{Start: i, Length: len(query)}}
+			}
+		}
+
+		items = append(items, term.ListItem[*history.Candidate]{
+			DisplayFields: []term.FormattedContent{cmd, {Content: fmt.Sprintf("%d uses", cand.Count)}},
+			Raw:           cand,
+		})
+	}
+
+	return items, nil
+}
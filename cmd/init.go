@@ -2,14 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
 const (
 	zshShell              = "zsh"
+	bashShell             = "bash"
 	fishShell             = "fish"
-	addPrintCommandEnvVar = "SPEEDDIAL_ADD_PRINT_COMMAND"
+	previousCommandEnvVar = "SPEEDDIAL_PREVIOUS_COMMAND"
 	initializedEnvVar     = "SPEEDDIAL_INITIALIZED"
 )
 
@@ -20,30 +23,106 @@ var (
 		Long: `Setup the shell wrapper for speeddial.
 
 For zsh, add the following to your rc file: eval "$(speeddial init zsh)"
+For bash, add the following to your rc file: eval "$(speeddial init bash)"
 For fish, add the following to your rc file: speeddial init fish | source
 
+With --bind, a key binding is also set up which opens speeddial from the line being composed,
+using it as the initial query, and replaces the line with the selected command. Keys are given
+in caret notation, e.g. '^G' for ctrl-g or '^[g' for alt-g.
+
 Check https://github.com/rithvikp/speeddial for more information.`,
 
 		Args:      cobra.ExactValidArgs(1),
-		ValidArgs: []string{zshShell, fishShell},
+		ValidArgs: []string{zshShell, bashShell, fishShell},
 		Run:       runInit,
 	}
+
+	initBindArg string
 )
 
+func init() {
+	initCmd.Flags().StringVar(&initBindArg, "bind", "", "A key (e.g. '^G') that opens speeddial from the line being composed")
+}
+
 func runInit(cmd *cobra.Command, args []string) {
+	var key hotkey
+	if initBindArg != "" {
+		var err error
+		key, err = parseHotkey(initBindArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to set up the key binding: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	switch args[0] {
 	case zshShell:
 		fmt.Println(zshInitialization)
+		if initBindArg != "" {
+			fmt.Printf(zshWidget, key.zsh())
+		}
+	case bashShell:
+		fmt.Println(bashInitialization)
+		if initBindArg != "" {
+			fmt.Printf(bashWidget, key.bash())
+		}
 	case fishShell:
 		fmt.Println(fishInitialization)
+		if initBindArg != "" {
+			fmt.Printf(fishWidget, key.fish())
+		}
+	}
+}
+
+// hotkey is a key combination that can be bound in each of the supported shells.
+type hotkey struct {
+	// If alt is false, the key is the control key pressed along with char.
+	alt  bool
+	char byte
+}
+
+// parseHotkey parses a key in caret notation: either "^X" for ctrl-x or "^[x" for alt-x.
+func parseHotkey(s string) (hotkey, error) {
+	isLetter := func(c byte) bool {
+		return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	}
+
+	// Control can only be combined with letters, but alt can also be combined with digits
+	if len(s) == 2 && s[0] == '^' && isLetter(s[1]) {
+		return hotkey{char: strings.ToLower(s[1:])[0]}, nil
+	} else if len(s) == 3 && strings.HasPrefix(s, "^[") && (isLetter(s[2]) || (s[2] >= '0' && s[2] <= '9')) {
+		return hotkey{alt: true, char: s[2]}, nil
+	}
+
+	return hotkey{}, fmt.Errorf("%q is not a supported key: use '^X' for ctrl-x or '^[x' for alt-x", s)
+}
+
+func (k hotkey) zsh() string {
+	if k.alt {
+		return "^[" + string(k.char)
+	}
+	return "^" + strings.ToUpper(string(k.char))
+}
+
+func (k hotkey) bash() string {
+	if k.alt {
+		return `\e` + string(k.char)
+	}
+	return `\C-` + string(k.char)
+}
+
+func (k hotkey) fish() string {
+	if k.alt {
+		return `\e` + string(k.char)
 	}
+	return `\c` + string(k.char)
 }
 
 const (
 	zshInitialization = `
 spd() {
-    if [ "$1" = "add" ] && [ "$#" = 1 ]; then
-		SPEEDDIAL_INITIALIZED=1 SPEEDDIAL_ADD_PRINT_COMMAND=1 speeddial add "$(fc -ln -1)"
+    if [ "$1" = "add" ]; then
+        SPEEDDIAL_INITIALIZED=1 SPEEDDIAL_PREVIOUS_COMMAND="$(fc -ln -1)" speeddial "$@"
     elif [ "$1" = "" ]; then
         print -z $(SPEEDDIAL_INITIALIZED=1 speeddial)
     else
@@ -51,14 +130,95 @@ spd() {
     fi
 }`
 
+	// Bash functions cannot push text onto the next prompt like zsh's "print -z", so the selection
+	// is stashed and the terminal is asked for its status. Readline receives the terminal's reply
+	// ("\e[0n") as input at the next prompt, which triggers a binding that fills in the line.
+	//
+	// The "spd add" invocation is already in the history when the function runs (unless it was
+	// ignored through HISTCONTROL), in which case the command before it is used.
+	bashInitialization = `
+__speeddial_previous_command() {
+    __speeddial_previous="$(fc -ln -1)"
+    if [[ $__speeddial_previous =~ ^[[:space:]]*spd([[:space:]]|$) ]]; then
+        __speeddial_previous="$(fc -ln -2 -2)"
+    fi
+}
+
+__speeddial_insert() {
+    READLINE_LINE="$__speeddial_selection"
+    READLINE_POINT=${#READLINE_LINE}
+    __speeddial_selection=
+}
+
+spd() {
+    if [ "$1" = "add" ]; then
+        __speeddial_previous_command
+        SPEEDDIAL_INITIALIZED=1 SPEEDDIAL_PREVIOUS_COMMAND="$__speeddial_previous" speeddial "$@"
+    elif [ "$1" = "" ]; then
+        __speeddial_selection="$(SPEEDDIAL_INITIALIZED=1 speeddial)"
+        if [ -n "$__speeddial_selection" ]; then
+            printf '\e[5n'
+        fi
+    else
+        SPEEDDIAL_INITIALIZED=1 speeddial "$@"
+    fi
+}
+
+if [[ $- == *i* ]]; then
+    bind -x '"\e[0n": __speeddial_insert'
+fi`
+
 	fishInitialization = `
 function spd
-    if test "$argv[1]" = "add"; and test (count $argv) = 1
-        SPEEDDIAL_INITIALIZED=1 SPEEDDIAL_ADD_PRINT_COMMAND=1 speeddial add "$history[1]"
+    if test "$argv[1]" = "add"
+        SPEEDDIAL_INITIALIZED=1 SPEEDDIAL_PREVIOUS_COMMAND="$history[1]" speeddial $argv
     else if test (count $argv) = 0
         commandline (SPEEDDIAL_INITIALIZED=1 speeddial)
     else
         SPEEDDIAL_INITIALIZED=1 speeddial $argv
     end
 end`
+
+	// The widgets are formatted with the key to bind. The selection is read from stdout, so the
+	// terminal is explicitly passed in as stdin for the interface.
+	zshWidget = `
+__speeddial_widget() {
+    local selection
+    selection="$(SPEEDDIAL_INITIALIZED=1 speeddial --query="$BUFFER" </dev/tty)"
+    if [ -n "$selection" ]; then
+        BUFFER="$selection"
+        CURSOR=${#BUFFER}
+    fi
+    zle reset-prompt
+}
+zle -N __speeddial_widget
+bindkey '%s' __speeddial_widget
+`
+
+	bashWidget = `
+__speeddial_widget() {
+    local selection
+    selection="$(SPEEDDIAL_INITIALIZED=1 speeddial --query="$READLINE_LINE" </dev/tty)"
+    if [ -n "$selection" ]; then
+        READLINE_LINE="$selection"
+        READLINE_POINT=${#READLINE_LINE}
+    fi
+}
+
+if [[ $- == *i* ]]; then
+    bind -x '"%s": __speeddial_widget'
+fi
+`
+
+	fishWidget = `
+function __speeddial_widget
+    set -l selection (SPEEDDIAL_INITIALIZED=1 speeddial --query=(commandline | string collect) </dev/tty | string collect)
+    if test -n "$selection"
+        commandline -r -- $selection
+    end
+    commandline -f repaint
+end
+bind %[1]s __speeddial_widget
+bind -M insert %[1]s __speeddial_widget
+`
 )
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBashInitialization(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "init.bash")
+	if err := os.WriteFile(script, []byte(bashInitialization+fmt.Sprintf(bashWidget, hotkey{char: 'g'}.bash())), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, err := exec.Command(bash, "-n", script).CombinedOutput(); err != nil {
+		t.Fatalf("The bash initialization script is invalid: %v\n%s", err, out)
+	}
+
+	tests := []struct {
+		msg     string
+		history string
+		want    string
+	}{
+		{msg: "spd add is in the history", history: "git status\nspd add -t git\n", want: "git status"},
+		{msg: "spd add is not in the history", history: "git status\nls -la\n", want: "ls -la"},
+	}
+
+	for _, tt := range tests {
+		histFile := filepath.Join(dir, "history")
+		if err := os.WriteFile(histFile, []byte(tt.history), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := exec.Command(bash, "-c", `set -o history; source "$1"; history -r "$2"; __speeddial_previous_command; echo "$__speeddial_previous"`, "bash", script, histFile)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Unable to run the bash initialization script: %v", err)
+		}
+
+		if got := strings.TrimSpace(string(out)); got != tt.want {
+			t.Errorf("The previous command when %s was %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+
+	out, err := exec.Command(bash, "-c", `source "$1"; __speeddial_selection="echo hi"; __speeddial_insert; printf '%s|%s' "$READLINE_LINE" "$READLINE_POINT"`, "bash", script).Output()
+	if err != nil {
+		t.Fatalf("Unable to run the bash initialization script: %v", err)
+	}
+	if got, want := string(out), "echo hi|7"; got != want {
+		t.Errorf("The readline buffer after inserting the selection was %q, want %q", got, want)
+	}
+}
+
+func TestParseHotkey(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    []string
+		wantErr bool
+	}{
+		{key: "^G", want: []string{"^G", `\C-g`, `\cg`}},
+		{key: "^g", want: []string{"^G", `\C-g`, `\cg`}},
+		{key: "^[g", want: []string{"^[g", `\eg`, `\eg`}},
+		{key: "^[1", want: []string{"^[1", `\e1`, `\e1`}},
+		{key: "^1", wantErr: true},
+		{key: "g", wantErr: true},
+		{key: "^['", wantErr: true},
+		{key: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		k, err := parseHotkey(tt.key)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Expected an error when parsing %q", tt.key)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("Unable to parse %q: %v", tt.key, err)
+			continue
+		}
+
+		if diff := cmp.Diff([]string{k.zsh(), k.bash(), k.fish()}, tt.want); diff != "" {
+			t.Errorf("Bindings for %q diff (-got, +want):\n%s", tt.key, diff)
+		}
+	}
+}
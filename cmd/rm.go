@@ -3,7 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/rithvikp/speeddial/state"
 	"github.com/rithvikp/speeddial/term"
 	"github.com/spf13/cobra"
 )
@@ -11,8 +13,8 @@ import (
 var (
 	rmCmd = &cobra.Command{
 		Use:   "rm",
-		Short: "Remove a command from speeddial",
-		Long:  `Use the search menu and the arrow keys to select an entry and then press \"enter\" to delete it. Confirm this deletion by pressing "y"`,
+		Short: "Remove commands from speeddial",
+		Long:  `Use the search menu and the arrow keys to select an entry and then press "enter" to delete it. To delete multiple entries at once, mark them with "tab" ("alt-a" marks every matching entry and "alt-n" clears the marks) before pressing "enter". Confirm the deletion by pressing "y"`,
 
 		Run: runRm,
 	}
@@ -28,9 +30,13 @@ func runRm(cmd *cobra.Command, args []string) {
 	c := setup()
 	defer dump(c)
 
-	command := search(c, rmRegexArg)
+	commands := searchMulti(c, state.SearchOptions{Mode: searchMode(rmRegexArg)}, "")
+	if len(commands) == 0 {
+		fmt.Fprintln(os.Stderr, "No commands were selected")
+		os.Exit(0) // nolint:gocritic // It is ok that the deferred dump does not run since there was no state update.
+	}
 
-	confirm, err := term.Confirmation(fmt.Sprintf("Are you sure you want to delete command `%s`?", command.Invocation), true)
+	confirm, err := term.Confirmation(deletionPrompt(commands), true)
 	if err == term.ErrUserQuit {
 		os.Exit(0) // nolint:gocritic // It is ok that the deferred dump does not run since there was no state update.
 	} else if err != nil {
@@ -41,8 +47,24 @@ func runRm(cmd *cobra.Command, args []string) {
 		os.Exit(0)
 	}
 
-	err = c.DeleteCommand(command)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Unable to delete the command: %v\n", err)
+	for _, command := range commands {
+		if err := c.DeleteCommand(command); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to delete `%s`: %v\n", command.Invocation, err)
+		}
+	}
+}
+
+// deletionPrompt asks the user to confirm the deletion of the commands, listing each one if there
+// are multiple.
+func deletionPrompt(commands []*state.Command) string {
+	if len(commands) == 1 {
+		return fmt.Sprintf("Are you sure you want to delete command `%s`?", commands[0].Invocation)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Are you sure you want to delete these %d commands?\n", len(commands))
+	for _, command := range commands {
+		fmt.Fprintf(&b, "  %s\n", describeCommand(command))
 	}
+	return strings.TrimSuffix(b.String(), "\n")
 }
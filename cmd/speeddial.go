@@ -3,7 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/pterm/pterm"
 	"github.com/rithvikp/speeddial/state"
 	"github.com/rithvikp/speeddial/term"
 	"github.com/spf13/cobra"
@@ -17,17 +19,25 @@ var (
 	rootCmd = &cobra.Command{
 		Use:   "speeddial",
 		Short: "Shell commands at your fingertips",
-		Long:  `After starting this command, type and use the arrow keys to search for the entry you desire. Press "enter" to select the entry: it will be loaded into the subsequent terminal prompt.`,
+		Long:  `After starting this command, type and use the arrow keys to search for the entry you desire. Press "enter" to select the entry: it will be loaded into the subsequent terminal prompt. Press "ctrl-p" to toggle a preview of the selected entry's full details and "ctrl-r" to cycle between fuzzy, exact, regex and prefix search. The selected entry can also be acted on without leaving the search: press "ctrl-d" to delete it, "ctrl-o" to open it in your editor ($VISUAL or $EDITOR), "ctrl-y" to copy it to the clipboard and "ctrl-x" to run it right away.`,
 
 		Run: run,
 	}
 
-	rootRegexArg bool
+	rootRegexArg            bool
+	rootIgnoreDiacriticsArg bool
+	rootIgnoreCaseArg       bool
+	rootQueryArg            string
+	rootHeightArg           string
 )
 
 func init() {
-	rootCmd.AddCommand(addCmd, initCmd, rmCmd)
+	rootCmd.AddCommand(addCmd, dedupeCmd, editCmd, exportCmd, importCmd, initCmd, rmCmd)
 	rootCmd.Flags().BoolVarP(&rootRegexArg, "regex", "r", false, "Use regex instead of fuzzy search")
+	rootCmd.Flags().BoolVarP(&rootIgnoreCaseArg, "ignore-case", "i", false, "Ignore case when using regex search (toggle with alt-c while searching)")
+	rootCmd.Flags().BoolVar(&rootIgnoreDiacriticsArg, "ignore-diacritics", false, "Match accented characters to their base characters (e.g. \"e\" matches \"é\")")
+	rootCmd.Flags().StringVarP(&rootQueryArg, "query", "q", "", "The initial search query")
+	rootCmd.PersistentFlags().StringVar(&rootHeightArg, "height", "", "The maximum height of lists, in rows or as a percentage of the terminal's height (e.g. 40%)")
 }
 
 // Text output is printed to stderr instead of stdout as what is sent to stderr is printed right
@@ -59,13 +69,68 @@ func dump(c *state.Container) {
 
 func run(cmd *cobra.Command, args []string) {
 	c := setup()
-	fmt.Println(search(c, rootRegexArg).Invocation)
+	defer dump(c)
+
+	command := search(c, state.SearchOptions{
+		Mode:             searchMode(rootRegexArg),
+		IgnoreDiacritics: rootIgnoreDiacriticsArg,
+		CaseInsensitive:  rootIgnoreCaseArg,
+	}, rootQueryArg, pickerActions(c)...)
+
+	invocation := command.Invocation
+	if placeholders := command.Placeholders(); len(placeholders) > 0 {
+		var err error
+		invocation, err = fillPlaceholders(c, command, placeholders)
+		if err == term.ErrUserQuit {
+			return
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to fill in the placeholders: %v\n", err)
+			dump(c)
+			os.Exit(1)
+		}
+	}
+
+	command.RecordUse()
+	fmt.Println(invocation)
 }
 
-func search(c *state.Container, useRegex bool) *state.Command {
-	searcher := term.QueryableList[*state.Command](c.Searcher(useRegex))
-	command, err := term.List(searcher, maxDisplayedSearchResults, true)
-	if err == term.ErrUserQuit {
+// fillPlaceholders prompts the user for the value of each placeholder in the command, returning
+// the completed invocation. term.ErrUserQuit is returned if the user quits the form.
+func fillPlaceholders(c *state.Container, command *state.Command, placeholders []state.Placeholder) (string, error) {
+	fields := make([]term.FormField, 0, len(placeholders))
+	for _, p := range placeholders {
+		f := term.FormField{
+			Label:   p.Name,
+			Value:   p.Default,
+			History: c.RecentValues(p.Name),
+		}
+		if f.Value == "" && len(f.History) > 0 {
+			f.Value = f.History[0]
+		}
+		fields = append(fields, f)
+	}
+
+	input, err := term.Form(fmt.Sprintf("Fill in the placeholders for `%s`:", command.Invocation), fields)
+	if err != nil {
+		return "", err
+	}
+
+	values := make(map[string]string, len(placeholders))
+	for i, p := range placeholders {
+		values[p.Name] = input[i]
+	}
+	c.RememberValues(values)
+
+	return command.Fill(values), nil
+}
+
+// search lets the user select a command, with the given actions available while searching. If the
+// user quits, the state is dumped (as actions may have modified it) and the program exits.
+func search(c *state.Container, opts state.SearchOptions, query string, actions ...term.Action[*state.Command]) *state.Command {
+	searcher := term.QueryableList[*state.Command](c.Searcher(opts))
+	command, err := term.List(searcher, listOptions(query), actions...)
+	if err == term.ErrUserQuit || err == term.ErrClosedByAction {
+		dump(c)
 		os.Exit(0)
 	} else if err != nil {
 		fmt.Fprintf(os.Stderr, "Unable to select a new command: %v\n", err)
@@ -74,3 +139,63 @@ func search(c *state.Container, useRegex bool) *state.Command {
 
 	return command
 }
+
+// searchMulti is like search, but allows the user to select multiple commands.
+func searchMulti(c *state.Container, opts state.SearchOptions, query string) []*state.Command {
+	searcher := term.QueryableList[*state.Command](c.Searcher(opts))
+	commands, err := term.ListMulti(searcher, listOptions(query))
+	if err == term.ErrUserQuit {
+		os.Exit(0)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to select commands: %v\n", err)
+		os.Exit(1)
+	}
+
+	return commands
+}
+
+// listOptions returns the options for lists with the given initial query. Without the --height
+// flag, a fixed number of items is shown; with it, as many items as fit within the height.
+func listOptions(query string) term.ListOptions {
+	opts := term.ListOptions{
+		MaxToDisplay:  maxDisplayedSearchResults,
+		VimNavigation: true,
+		Query:         query,
+	}
+	if rootHeightArg == "" {
+		return opts
+	}
+
+	height, err := term.ParseHeight(rootHeightArg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to use the list height: %v\n", err)
+		os.Exit(1)
+	}
+	opts.Height = height
+	opts.MaxToDisplay = 0
+	return opts
+}
+
+// searchMode returns the initial search mode, which is regex if the --regex flag was given and
+// fuzzy otherwise.
+func searchMode(regex bool) state.SearchMode {
+	if regex {
+		return state.RegexMode
+	}
+	return state.FuzzyMode
+}
+
+// describeCommand formats a command, along with its metadata, for display on a single line.
+func describeCommand(c *state.Command) string {
+	parts := []string{pterm.Bold.Sprint(c.Invocation)}
+	if c.Description != "" {
+		parts = append(parts, c.Description)
+	}
+	for _, t := range c.Tags {
+		parts = append(parts, pterm.Cyan("#"+t))
+	}
+	if src := c.Source(); src != "" {
+		parts = append(parts, pterm.Gray("("+src+")"))
+	}
+	return strings.Join(parts, " ")
+}
@@ -3,20 +3,23 @@ module github.com/rithvikp/speeddial
 go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/google/go-cmp v0.5.8
+	github.com/mattn/go-runewidth v0.0.13
 	github.com/pterm/pterm v0.12.33
 	github.com/spf13/cobra v1.2.1
+	golang.org/x/exp v0.0.0-20220722155223-a9213eeb770e
+	golang.org/x/sys v0.0.0-20220722155257-8c9f86f7a55f
 	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
+	golang.org/x/text v0.3.8
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/atomicgo/cursor v0.0.1 // indirect
 	github.com/gookit/color v1.4.2 // indirect
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
-	github.com/mattn/go-runewidth v0.0.13 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/xo/terminfo v0.0.0-20210125001918-ca9a967f8778 // indirect
-	golang.org/x/exp v0.0.0-20220722155223-a9213eeb770e // indirect
-	golang.org/x/sys v0.0.0-20211019181941-9d821ace8654 // indirect
 )
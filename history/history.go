@@ -0,0 +1,287 @@
+// Package history parses shell history files.
+package history
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported shells.
+const (
+	Zsh  = "zsh"
+	Bash = "bash"
+	Fish = "fish"
+)
+
+// Entry is a single command from a shell's history. Time is the zero value if the history file does
+// not record when the command was run.
+type Entry struct {
+	Command string
+	Time    time.Time
+}
+
+// Candidate is a distinct command from a shell's history along with how often and when it was most
+// recently run.
+type Candidate struct {
+	Command string
+	Count   int
+	Last    time.Time
+}
+
+// Parse parses the history file for the given shell.
+func Parse(shell string, r io.Reader) ([]Entry, error) {
+	switch shell {
+	case Zsh:
+		return ParseZsh(r)
+	case Bash:
+		return ParseBash(r)
+	case Fish:
+		return ParseFish(r)
+	}
+	return nil, fmt.Errorf("%q is not a supported shell", shell)
+}
+
+// ParseZsh parses zsh history, which is in the extended format (": <timestamp>:<duration>;<command>")
+// if EXTENDED_HISTORY is set and otherwise just contains commands. Commands which span multiple
+// lines are written with a trailing backslash on every line but the last.
+func ParseZsh(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var current *Entry
+
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := unmetafy(scanner.Text())
+
+		if current == nil {
+			current = &Entry{}
+			if ts, cmd, ok := parseZshExtended(line); ok {
+				current.Time = ts
+				line = cmd
+			}
+		} else {
+			current.Command += "\n"
+		}
+
+		if strings.HasSuffix(line, "\\") {
+			current.Command += line[:len(line)-1]
+			continue
+		}
+
+		current.Command += line
+		entries = appendEntry(entries, *current)
+		current = nil
+	}
+
+	if current != nil {
+		entries = appendEntry(entries, *current)
+	}
+	return entries, scanner.Err()
+}
+
+// parseZshExtended splits a line in zsh's extended history format into its timestamp and command.
+func parseZshExtended(line string) (time.Time, string, bool) {
+	if !strings.HasPrefix(line, ": ") {
+		return time.Time{}, "", false
+	}
+
+	meta, cmd, found := strings.Cut(line[2:], ";")
+	if !found {
+		return time.Time{}, "", false
+	}
+
+	ts, _, found := strings.Cut(meta, ":")
+	if !found {
+		return time.Time{}, "", false
+	}
+
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+
+	return time.Unix(secs, 0).UTC(), cmd, true
+}
+
+// unmetafy reverses zsh's encoding of special bytes in history files, where such bytes are
+// written as 0x83 followed by the original byte XOR 32.
+func unmetafy(s string) string {
+	const meta = 0x83
+	if strings.IndexByte(s, meta) < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == meta && i+1 < len(s) {
+			i++
+			b.WriteByte(s[i] ^ 32)
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// ParseBash parses bash history. If HISTTIMEFORMAT was set, each command is preceded by a comment
+// line with its timestamp (e.g. "#1650000000"), and every line up to the next timestamp is part of
+// the same command. Otherwise, every line is a separate command.
+func ParseBash(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var current *Entry
+
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if ts, ok := parseBashTimestamp(line); ok {
+			if current != nil {
+				entries = appendEntry(entries, *current)
+			}
+			current = &Entry{Time: ts}
+			continue
+		}
+
+		if current == nil {
+			entries = appendEntry(entries, Entry{Command: line})
+		} else if current.Command == "" {
+			current.Command = line
+		} else {
+			current.Command += "\n" + line
+		}
+	}
+
+	if current != nil {
+		entries = appendEntry(entries, *current)
+	}
+	return entries, scanner.Err()
+}
+
+func parseBashTimestamp(line string) (time.Time, bool) {
+	if len(line) < 2 || line[0] != '#' {
+		return time.Time{}, false
+	}
+
+	secs, err := strconv.ParseInt(line[1:], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(secs, 0).UTC(), true
+}
+
+// ParseFish parses fish history, which is a YAML-like list of entries. Each entry starts with a
+// "- cmd: <command>" line, followed by indented "when: <timestamp>" and "paths:" lines. Newlines and backslashes in commands are escaped as "\n" and "\\" respectively.
+func ParseFish(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var current *Entry
+
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if cmd, found := cutPrefix(line, "- cmd: "); found {
+			if current != nil {
+				entries = appendEntry(entries, *current)
+			}
+			current = &Entry{Command: unescapeFish(cmd)}
+		} else if when, found := cutPrefix(line, "  when: "); found && current != nil {
+			secs, err := strconv.ParseInt(strings.TrimSpace(when), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q in fish history: %v", when, err)
+			}
+			current.Time = time.Unix(secs, 0).UTC()
+		}
+	}
+
+	if current != nil {
+		entries = appendEntry(entries, *current)
+	}
+	return entries, scanner.Err()
+}
+
+func unescapeFish(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Rank combines duplicate history entries into candidates, ordering them by how often they were run
+// and then by how recently. Ties are broken alphabetically so that ranking is deterministic.
+func Rank(entries []Entry) []Candidate {
+	indices := make(map[string]int)
+	var candidates []Candidate
+
+	for _, e := range entries {
+		i, ok := indices[e.Command]
+		if !ok {
+			i = len(candidates)
+			indices[e.Command] = i
+			candidates = append(candidates, Candidate{Command: e.Command})
+		}
+
+		c := &candidates[i]
+		c.Count++
+		if e.Time.After(c.Last) {
+			c.Last = e.Time
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		} else if !a.Last.Equal(b.Last) {
+			return a.Last.After(b.Last)
+		}
+		return a.Command < b.Command
+	})
+
+	return candidates
+}
+
+// appendEntry adds the entry to the list with surrounding whitespace removed, skipping it if it is
+// empty.
+func appendEntry(entries []Entry, e Entry) []Entry {
+	e.Command = strings.TrimSpace(e.Command)
+	if e.Command == "" {
+		return entries
+	}
+	return append(entries, e)
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	// Some commands (e.g. with inlined scripts) can be very long
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return scanner
+}
+
+// cutPrefix is equivalent to strings.CutPrefix, which is not available in Go 1.18.
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
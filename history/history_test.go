@@ -0,0 +1,135 @@
+package history
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	ts := func(secs int64) time.Time {
+		return time.Unix(secs, 0).UTC()
+	}
+
+	tests := []struct {
+		msg     string
+		shell   string
+		content string
+		entries []Entry
+	}{
+		{
+			msg:     "zsh extended history",
+			shell:   Zsh,
+			content: ": 1650000000:0;git status\n: 1650000005:3;go test ./...\n",
+			entries: []Entry{
+				{Command: "git status", Time: ts(1650000000)},
+				{Command: "go test ./...", Time: ts(1650000005)},
+			},
+		},
+		{
+			msg:     "zsh multi-line commands",
+			shell:   Zsh,
+			content: ": 1650000000:0;for f in *.go; do\\\n  gofmt -l $f\\\ndone\n: 1650000010:0;ls\n",
+			entries: []Entry{
+				{Command: "for f in *.go; do\n  gofmt -l $f\ndone", Time: ts(1650000000)},
+				{Command: "ls", Time: ts(1650000010)},
+			},
+		},
+		{
+			msg:     "zsh plain history with a semicolon",
+			shell:   Zsh,
+			content: "make build; make run\n\n",
+			entries: []Entry{
+				{Command: "make build; make run"},
+			},
+		},
+		{
+			msg:     "zsh metafied bytes",
+			shell:   Zsh,
+			content: ": 1650000000:0;echo caf\xc3\x83\xa9\n",
+			entries: []Entry{
+				{Command: "echo caf\xc3\x89", Time: ts(1650000000)},
+			},
+		},
+		{
+			msg:     "bash history without timestamps",
+			shell:   Bash,
+			content: "git status\ngo build\n  \n",
+			entries: []Entry{
+				{Command: "git status"},
+				{Command: "go build"},
+			},
+		},
+		{
+			msg:     "bash history with timestamps and multi-line commands",
+			shell:   Bash,
+			content: "#1650000000\ngit status\n#1650000020\nfor f in *; do\necho $f\ndone\n#1650000030\n# a comment\n",
+			entries: []Entry{
+				{Command: "git status", Time: ts(1650000000)},
+				{Command: "for f in *; do\necho $f\ndone", Time: ts(1650000020)},
+				{Command: "# a comment", Time: ts(1650000030)},
+			},
+		},
+		{
+			msg:   "fish history",
+			shell: Fish,
+			content: `- cmd: git status
+  when: 1650000000
+- cmd: echo "a\nb" \\ c
+  when: 1650000010
+  paths:
+    - some/path
+- cmd: ls
+`,
+			entries: []Entry{
+				{Command: "git status", Time: ts(1650000000)},
+				{Command: "echo \"a\nb\" \\ c", Time: ts(1650000010)},
+				{Command: "ls"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			entries, err := Parse(tt.shell, strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatalf("Unable to parse the history: %v", err)
+			}
+
+			if diff := cmp.Diff(entries, tt.entries); diff != "" {
+				t.Errorf("[]Entry diff (-got, +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	ts := func(secs int64) time.Time {
+		return time.Unix(secs, 0).UTC()
+	}
+
+	entries := []Entry{
+		{Command: "ls", Time: ts(10)},
+		{Command: "git status", Time: ts(20)},
+		{Command: "go build", Time: ts(30)},
+		{Command: "ls", Time: ts(40)},
+		{Command: "git status", Time: ts(5)},
+		{Command: "make"},
+		{Command: "cargo build"},
+	}
+
+	want := []Candidate{
+		{Command: "ls", Count: 2, Last: ts(40)},
+		{Command: "git status", Count: 2, Last: ts(20)},
+		{Command: "go build", Count: 1, Last: ts(30)},
+		{Command: "cargo build", Count: 1},
+		{Command: "make", Count: 1},
+	}
+
+	if diff := cmp.Diff(Rank(entries), want); diff != "" {
+		t.Errorf("[]Candidate diff (-got, +want):\n%s", diff)
+	}
+}
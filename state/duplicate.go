@@ -0,0 +1,216 @@
+package state
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+)
+
+// normalizeInvocation converts an invocation into a canonical form so that near-duplicates can be
+// detected. The invocation is split into words like a shell would, so differences in whitespace
+// and quoting (e.g. 'a b', "a b" and a\ b) do not matter. Unterminated quotes are treated as if
+// they were closed at the end of the invocation.
+func normalizeInvocation(invocation string) string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	const (
+		unquoted = iota
+		singleQuoted
+		doubleQuoted
+	)
+	mode := unquoted
+
+	runes := []rune(invocation)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch mode {
+		case singleQuoted:
+			if r == '\'' {
+				mode = unquoted
+			} else {
+				word.WriteRune(r)
+			}
+			continue
+
+		case doubleQuoted:
+			if r == '"' {
+				mode = unquoted
+			} else if r == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+				i++
+				word.WriteRune(runes[i])
+			} else {
+				word.WriteRune(r)
+			}
+			continue
+		}
+
+		switch {
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '\'':
+			mode = singleQuoted
+			inWord = true
+		case r == '"':
+			mode = doubleQuoted
+			inWord = true
+		case r == '\\' && i+1 < len(runes):
+			i++
+			// A backslash-newline is a line continuation
+			if runes[i] != '\n' {
+				word.WriteRune(runes[i])
+				inWord = true
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	// Words can contain spaces after quotes are removed, so a separator that cannot appear in
+	// shell input is used.
+	return strings.Join(words, "\x00")
+}
+
+// FindDuplicate returns an existing command whose invocation is the same as the given one after
+// normalizing whitespace and quoting, or nil if there is no such command.
+func (c *Container) FindDuplicate(invocation string) *Command {
+	normalized := normalizeInvocation(invocation)
+	for _, s := range c.states {
+		for _, command := range s.Commands {
+			if normalizeInvocation(command.Invocation) == normalized {
+				return command
+			}
+		}
+	}
+	return nil
+}
+
+// InvocationSet is a set of invocations, which are compared after normalizing whitespace and
+// quoting (as in FindDuplicate).
+type InvocationSet struct {
+	normalized map[string]bool
+}
+
+// Invocations returns the set of the invocations of every command. Checking many invocations
+// against the set is faster than calling FindDuplicate for each one, which compares the
+// invocation to every command.
+func (c *Container) Invocations() *InvocationSet {
+	set := &InvocationSet{normalized: make(map[string]bool)}
+	for _, s := range c.states {
+		for _, command := range s.Commands {
+			set.Add(command.Invocation)
+		}
+	}
+	return set
+}
+
+// Contains determines whether the set has an invocation that is the same as the given one after
+// normalization.
+func (s *InvocationSet) Contains(invocation string) bool {
+	return s.normalized[normalizeInvocation(invocation)]
+}
+
+// Add adds the invocation to the set, e.g. after a command with the invocation has been added.
+func (s *InvocationSet) Add(invocation string) {
+	s.normalized[normalizeInvocation(invocation)] = true
+}
+
+// Duplicates returns groups of two or more commands whose invocations are the same after
+// normalization. Groups are ordered by the position of their first command.
+func (c *Container) Duplicates() [][]*Command {
+	indices := make(map[string]int)
+	var groups [][]*Command
+
+	for _, command := range c.List() {
+		normalized := normalizeInvocation(command.Invocation)
+		i, ok := indices[normalized]
+		if !ok {
+			i = len(groups)
+			indices[normalized] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], command)
+	}
+
+	var dups [][]*Command
+	for _, g := range groups {
+		if len(g) > 1 {
+			dups = append(dups, g)
+		}
+	}
+	return dups
+}
+
+// MergeTags adds the given tags to the command, keeping its existing tags.
+func (c *Container) MergeTags(command *Command, tags []string) error {
+	return c.UpdateCommand(command, command.Invocation, command.Description, append(slices.Clone(command.Tags), tags...))
+}
+
+// MergeCommands merges the duplicates into keep and then deletes them. The tags and usage of the
+// duplicates are combined with those of keep, and keep takes the first non-empty description if it
+// does not have one. Duplicates in read-only sources cannot be deleted and are skipped.
+//
+// If keep belongs to a read-only source, it is left unchanged and the first writable duplicate is
+// kept as well, with the other writable duplicates merged into it instead, so that their tags and
+// usage are not lost.
+func (c *Container) MergeCommands(keep *Command, duplicates []*Command) error {
+	if keep.state == nil || !keep.state.writable {
+		i := slices.IndexFunc(duplicates, func(d *Command) bool {
+			return d.state != nil && d.state.writable
+		})
+		if i < 0 {
+			return nil
+		}
+		keep = duplicates[i]
+	}
+
+	desc := keep.Description
+	tags := slices.Clone(keep.Tags)
+	var usage *Usage
+	if keep.Usage != nil {
+		u := *keep.Usage
+		usage = &u
+	}
+
+	for _, d := range duplicates {
+		if d == keep || d.state == nil || !d.state.writable {
+			continue
+		}
+
+		if desc == "" {
+			desc = d.Description
+		}
+		tags = append(tags, d.Tags...)
+
+		if d.Usage != nil {
+			if usage == nil {
+				usage = &Usage{}
+			}
+			usage.Count += d.Usage.Count
+			if d.Usage.Last.After(usage.Last) {
+				usage.Last = d.Usage.Last
+			}
+		}
+
+		if err := c.DeleteCommand(d); err != nil {
+			return err
+		}
+	}
+
+	if err := c.UpdateCommand(keep, keep.Invocation, desc, tags); err != nil {
+		return err
+	}
+	keep.Usage = usage
+	return nil
+}
@@ -0,0 +1,161 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestNormalizeInvocation(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{a: "git log --graph --oneline", b: "git  log\t--graph   --oneline ", same: true},
+		{a: "echo 'a b'", b: `echo "a b"`, same: true},
+		{a: "echo 'a b'", b: `echo a\ b`, same: true},
+		{a: `echo "a \"b\""`, b: `echo 'a "b"'`, same: true},
+		{a: "git log \\\n  --graph", b: "git log --graph", same: true},
+		{a: "echo 'a b", b: "echo 'a b'", same: true},
+		{a: "echo 'a b'", b: "echo a b", same: false},
+		{a: "git log", b: "git Log", same: false},
+		{a: "echo '$HOME'", b: `echo "\$HOME"`, same: true},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeInvocation(tt.a) == normalizeInvocation(tt.b); got != tt.same {
+			t.Errorf("Normalized %q and %q are the same: %t, want %t", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
+
+func TestInvocations(t *testing.T) {
+	c, err := initialize(filepath.Join(t.TempDir(), "speeddial.json"))
+	if err != nil {
+		t.Fatalf("Unable to initialize the container: %v", err)
+	}
+	for _, inv := range []string{"git log --graph", "ls -la"} {
+		if err := c.NewCommand(inv, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set := c.Invocations()
+	set.Add("docker ps")
+	tests := []struct {
+		invocation string
+		want       bool
+	}{
+		{invocation: "git log --graph", want: true},
+		{invocation: "git  log '--graph'", want: true},
+		{invocation: "docker   ps", want: true},
+		{invocation: "git log", want: false},
+		{invocation: "ls", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := set.Contains(tt.invocation); got != tt.want {
+			t.Errorf("Contains(%q) = %t, want %t", tt.invocation, got, tt.want)
+		}
+	}
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	c, err := initialize(filepath.Join(t.TempDir(), "speeddial.json"))
+	if err != nil {
+		t.Fatalf("Unable to initialize the container: %v", err)
+	}
+
+	last := time.Date(2022, time.August, 1, 0, 0, 0, 0, time.UTC)
+	for _, command := range []*Command{
+		{Invocation: "git log --graph --oneline", Tags: []string{"git"}, Usage: &Usage{Count: 2, Last: last}},
+		{Invocation: "ls -la"},
+		{Invocation: "git log  --graph  --oneline", Description: "Show the commit graph", Tags: []string{"log"}},
+		{Invocation: "git log '--graph' --oneline", Usage: &Usage{Count: 3, Last: last.Add(time.Hour)}},
+	} {
+		if err := c.AddCommandIn(PersonalSource, command); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups := c.Duplicates()
+	if len(groups) != 1 || len(groups[0]) != 3 {
+		t.Fatalf("Duplicates returned %d group(s), want 1 group of 3 commands", len(groups))
+	}
+
+	if err := c.MergeCommands(groups[0][0], groups[0]); err != nil {
+		t.Fatalf("Unable to merge the duplicates: %v", err)
+	}
+
+	want := []*Command{
+		{
+			Invocation:  "git log --graph --oneline",
+			Description: "Show the commit graph",
+			Tags:        []string{"git", "log"},
+			Usage:       &Usage{Count: 5, Last: last.Add(time.Hour)},
+		},
+		{Invocation: "ls -la"},
+	}
+	if diff := cmp.Diff(c.List(), want, cmpopts.IgnoreUnexported(Command{})); diff != "" {
+		t.Errorf("Unexpected commands after merging (-got, +want):\n%s", diff)
+	}
+	if groups := c.Duplicates(); len(groups) != 0 {
+		t.Errorf("Duplicates returned %d group(s) after merging, want none", len(groups))
+	}
+}
+
+func TestMergeDuplicatesIntoReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	teamPath := filepath.Join(dir, "team.json")
+	raw, err := encodeState(&state{Commands: []*Command{{Invocation: "make deploy", Description: "Deploy"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(teamPath, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := initialize(filepath.Join(dir, "speeddial.json"))
+	if err != nil {
+		t.Fatalf("Unable to initialize the container: %v", err)
+	}
+	if err := c.Load(Source{Name: "team", Path: teamPath}); err != nil {
+		t.Fatalf("Unable to load the read-only source: %v", err)
+	}
+
+	last := time.Date(2022, time.August, 1, 0, 0, 0, 0, time.UTC)
+	for _, command := range []*Command{
+		{Invocation: "make  deploy", Tags: []string{"ops"}, Usage: &Usage{Count: 2, Last: last}},
+		{Invocation: "make 'deploy'", Tags: []string{"prod"}, Usage: &Usage{Count: 1, Last: last.Add(time.Hour)}},
+	} {
+		if err := c.AddCommandIn(PersonalSource, command); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups := c.Duplicates()
+	if len(groups) != 1 || len(groups[0]) != 3 {
+		t.Fatalf("Duplicates returned %d group(s), want 1 group of 3 commands", len(groups))
+	}
+	keep := c.source("team").Commands[0]
+	if err := c.MergeCommands(keep, groups[0]); err != nil {
+		t.Fatalf("Unable to merge the duplicates: %v", err)
+	}
+
+	// The personal tags and usage are kept in the first personal duplicate
+	want := []*Command{
+		{
+			Invocation: "make  deploy",
+			Tags:       []string{"ops", "prod"},
+			Usage:      &Usage{Count: 3, Last: last.Add(time.Hour)},
+		},
+		{Invocation: "make deploy", Description: "Deploy"},
+	}
+	if diff := cmp.Diff(c.List(), want, cmpopts.IgnoreUnexported(Command{})); diff != "" {
+		t.Errorf("Unexpected commands after merging (-got, +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,86 @@
+package state
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldedRune is a rune of text after folding, along with the original rune and the span of bytes
+// in the original text that it was folded from.
+type foldedRune struct {
+	r     rune
+	orig  rune
+	start int
+	end   int
+}
+
+// fold converts text into a sequence of runes that can be compared without regard to case and,
+// optionally, diacritics. Each folded rune remembers where it came from so that matches can be
+// reported as byte offsets into the original text.
+//
+// When diacritics are ignored, precomposed characters are reduced to their base character and
+// combining marks are merged into the preceding rune's span.
+func fold(s string, ignoreDiacritics bool) []foldedRune {
+	folded := make([]foldedRune, 0, len(s))
+	for i, orig := range s {
+		r := orig
+		_, size := utf8.DecodeRuneInString(s[i:])
+		end := i + size
+
+		if ignoreDiacritics {
+			if unicode.Is(unicode.Mn, r) && len(folded) > 0 {
+				folded[len(folded)-1].end = end
+				continue
+			}
+			r = stripDiacritics(r)
+		}
+
+		folded = append(folded, foldedRune{r: foldCase(r), orig: orig, start: i, end: end})
+	}
+	return folded
+}
+
+// foldRunes folds text that is only used for comparison, such as a query.
+func foldRunes(s string, ignoreDiacritics bool) []rune {
+	folded := fold(s, ignoreDiacritics)
+	runes := make([]rune, len(folded))
+	for i, f := range folded {
+		runes[i] = f.r
+	}
+	return runes
+}
+
+// foldCase maps every rune in a Unicode case folding orbit (e.g. k, K and the Kelvin sign) to the
+// same rune.
+func foldCase(r rune) rune {
+	// The orbits of ASCII letters all start with the uppercase letter
+	if r < utf8.RuneSelf {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}
+
+	folded := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < folded {
+			folded = f
+		}
+	}
+	return folded
+}
+
+// stripDiacritics returns the base character of a precomposed character like é. Characters which
+// do not decompose into a single base character and combining marks are returned unchanged.
+func stripDiacritics(r rune) rune {
+	decomposed := norm.NFD.String(string(r))
+	base, n := utf8.DecodeRuneInString(decomposed)
+	for _, m := range decomposed[n:] {
+		if !unicode.Is(unicode.Mn, m) {
+			return r
+		}
+	}
+	return base
+}
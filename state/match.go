@@ -0,0 +1,327 @@
+package state
+
+import (
+	"unicode"
+)
+
+// Scores used by match, modelled on fzf. Every matched character earns scoreMatch plus a bonus
+// based on where it is in the source, and every gap between matched characters is penalized.
+// Bonuses are chosen so that, for example, matching the start of a word is worth more than a
+// short gap, but a run of consecutive characters beats a scattered match.
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+
+	// Characters at the start of a word, where the previous character is not a letter or digit
+	bonusBoundary = scoreMatch / 2
+	// Words that follow whitespace or a separator (e.g. a path separator or pipe) are stronger
+	// boundaries than words after other punctuation like "-"
+	bonusBoundaryWhite     = bonusBoundary + 2
+	bonusBoundaryDelimiter = bonusBoundary + 1
+	// Matching punctuation is as valuable as matching the start of a word
+	bonusNonWord = scoreMatch / 2
+	// The start of a word in camelCase or a number after letters
+	bonusCamel123 = bonusBoundary + scoreGapExtension
+	// Every character in a consecutive run earns at least this bonus, which makes up for the
+	// penalty of a gap
+	bonusConsecutive = -(scoreGapStart + scoreGapExtension)
+	// The bonus of the first character of the query is multiplied, as it anchors the match
+	bonusFirstCharMultiplier = 2
+)
+
+type charClass int
+
+const (
+	charWhite charClass = iota
+	charNonWord
+	charDelimiter
+	charLower
+	charUpper
+	charLetter
+	charNumber
+)
+
+func classOf(r rune) charClass {
+	switch {
+	case unicode.IsSpace(r):
+		return charWhite
+	case r == '/' || r == ',' || r == ':' || r == ';' || r == '|':
+		return charDelimiter
+	case unicode.IsLower(r):
+		return charLower
+	case unicode.IsUpper(r):
+		return charUpper
+	case unicode.IsLetter(r):
+		return charLetter
+	case unicode.IsDigit(r):
+		return charNumber
+	}
+	return charNonWord
+}
+
+// bonusFor returns the bonus for matching a character of the given class which follows a
+// character of the class prev.
+func bonusFor(prev, class charClass) int {
+	if class > charDelimiter {
+		switch prev {
+		case charWhite:
+			return bonusBoundaryWhite
+		case charDelimiter:
+			return bonusBoundaryDelimiter
+		case charNonWord:
+			return bonusBoundary
+		}
+	}
+
+	if (prev == charLower && class == charUpper) || (prev != charNumber && class == charNumber) {
+		return bonusCamel123
+	}
+
+	switch class {
+	case charWhite:
+		return bonusBoundaryWhite
+	case charNonWord, charDelimiter:
+		return bonusNonWord
+	}
+	return 0
+}
+
+// match fuzzy matches the cleaned text of the query against src (see matchFuzzy).
+func match(q *query, src string) ([]matchedText, int) {
+	return matchFuzzy(q.cleaned, src, q.ignoreDiacritics)
+}
+
+// matchFuzzy determines whether the needle appears in src as a sequence of (potentially
+// non-consecutive) characters. If it does, the matched chunks of src are returned as byte offsets
+// along with a score, with higher being better. Matching is performed on folded runes (see fold).
+//
+// The best match is found with a Smith-Waterman style alignment in O(len(src)·len(needle)) time:
+// for each needle character j and source position i, the best score of matching the needle up to
+// j with j matched at i is computed from either a consecutive match at i-1 or the best match
+// before a gap. Ties are broken in favor of consecutive runs and then the earliest match. If the
+// needle occurs as a consecutive run, the best such run is returned without a scattered match
+// being considered.
+func matchFuzzy(rawNeedle, src string, ignoreDiacritics bool) ([]matchedText, int) {
+	needle := foldRunes(rawNeedle, ignoreDiacritics)
+	text := trimFolded(fold(src, ignoreDiacritics))
+	m, n := len(needle), len(text)
+	if m == 0 || n == 0 || m > n {
+		return nil, 0
+	}
+
+	// Quickly rule out sources which do not contain the query as a subsequence, and narrow the
+	// search to the section of src between the first possible start and last possible end.
+	first, j := 0, 0
+	for i := 0; i < n && j < m; i++ {
+		if text[i].r == needle[j] {
+			if j == 0 {
+				first = i
+			}
+			j++
+		}
+	}
+	if j < m {
+		return nil, 0
+	}
+	end := n
+	for text[end-1].r != needle[m-1] {
+		end--
+	}
+
+	bonus := make([]int, n)
+	prev := charWhite
+	for i := 0; i < n; i++ {
+		class := classOf(text[i].orig)
+		bonus[i] = bonusFor(prev, class)
+		prev = class
+	}
+
+	// A run of the whole needle beats any scattered match, even one that starts at a stronger
+	// boundary (e.g. "日本語" in "日本と日本語")
+	if start, s := bestRun(needle, text[first:end], bonus[first:end]); start >= 0 {
+		return byteOffsets(text, []matchedText{{start: first + start, length: m}}), s
+	}
+
+	// score[j*n+i] is the best score with needle[j] matched at text[i], or unmatched if it is not
+	// possible. from[j*n+i] is the position of needle[j-1] in that match, and runBonus[j*n+i] is
+	// the bonus of the first character in the consecutive run ending at text[i].
+	const unmatched = -1 << 30
+	score := make([]int, m*n)
+	from := make([]int, m*n)
+	runBonus := make([]int, m*n)
+	for k := range score {
+		score[k] = unmatched
+	}
+
+	for j := 0; j < m; j++ {
+		row := j * n
+		prevRow := (j - 1) * n
+
+		// The best score for needle[j-1] followed by a gap ending just before i, and where it was
+		gapBest, gapFrom := unmatched, -1
+
+		for i := first + j; i < end; i++ {
+			if j > 0 && i >= 2 {
+				gapBest += scoreGapExtension
+				if s := score[prevRow+i-2]; s != unmatched && s+scoreGapStart > gapBest {
+					gapBest, gapFrom = s+scoreGapStart, i-2
+				}
+			}
+
+			if text[i].r != needle[j] {
+				continue
+			}
+
+			if j == 0 {
+				score[row+i] = scoreMatch + bonus[i]*bonusFirstCharMultiplier
+				from[row+i] = -1
+				runBonus[row+i] = bonus[i]
+				continue
+			}
+
+			best, bestFrom, bestRun := unmatched, -1, 0
+			if s := score[prevRow+i-1]; s != unmatched {
+				b, run := consecutiveBonus(runBonus[prevRow+i-1], bonus[i])
+				best = s + scoreMatch + b
+				bestFrom, bestRun = i-1, run
+			}
+			if gapBest > unmatched/2 {
+				if s := gapBest + scoreMatch + bonus[i]; s > best {
+					best, bestFrom, bestRun = s, gapFrom, bonus[i]
+				}
+			}
+
+			if best > unmatched/2 {
+				score[row+i] = best
+				from[row+i] = bestFrom
+				runBonus[row+i] = bestRun
+			}
+		}
+	}
+
+	lastRow := (m - 1) * n
+	bestEnd := -1
+	for i := first + m - 1; i < end; i++ {
+		if score[lastRow+i] != unmatched && (bestEnd < 0 || score[lastRow+i] > score[lastRow+bestEnd]) {
+			bestEnd = i
+		}
+	}
+	if bestEnd < 0 {
+		return nil, 0
+	}
+
+	// Walk back through the match, collecting the positions into chunks
+	positions := make([]int, m)
+	for j, i := m-1, bestEnd; j >= 0; j-- {
+		positions[j] = i
+		i = from[j*n+i]
+	}
+
+	var chunks []matchedText
+	for _, p := range positions {
+		if k := len(chunks) - 1; k >= 0 && chunks[k].start+chunks[k].length == p {
+			chunks[k].length++
+			continue
+		}
+		chunks = append(chunks, matchedText{start: p, length: 1})
+	}
+
+	return byteOffsets(text, chunks), score[lastRow+bestEnd]
+}
+
+// consecutiveBonus returns the bonus for a character that continues a consecutive run, given the
+// bonus of the start of the run and the character's own bonus, along with the run's new bonus.
+// Consecutive characters keep the bonus of the start of their run, unless a stronger boundary is
+// reached.
+func consecutiveBonus(run, bonus int) (b, newRun int) {
+	if bonus >= bonusBoundary && bonus > run {
+		run = bonus
+	}
+	b = bonus
+	if run > b {
+		b = run
+	}
+	if bonusConsecutive > b {
+		b = bonusConsecutive
+	}
+	return b, run
+}
+
+// bestRun returns the start and score of the highest scoring occurrence of the needle as a
+// consecutive run in text, where bonus holds the bonus of each character of text. The start is -1
+// if the needle does not occur in text.
+func bestRun(needle []rune, text []foldedRune, bonus []int) (int, int) {
+	best, bestScore := -1, 0
+	for i := 0; i+len(needle) <= len(text); i++ {
+		if !hasFoldedPrefix(text[i:], needle) {
+			continue
+		}
+
+		score := scoreMatch + bonus[i]*bonusFirstCharMultiplier
+		run := bonus[i]
+		for k := 1; k < len(needle); k++ {
+			var b int
+			b, run = consecutiveBonus(run, bonus[i+k])
+			score += scoreMatch + b
+		}
+		if best < 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best, bestScore
+}
+
+// matchLiteral determines whether the needle appears in src as a substring (termExact), at the
+// start of src (termPrefix) or at the end of src (termSuffix), ignoring surrounding whitespace.
+// Like matchFuzzy, it returns the matched chunk as a byte offset along with a score. If an exact
+// needle appears multiple times, the occurrence that starts at the strongest word boundary wins.
+func matchLiteral(kind termKind, rawNeedle, src string, ignoreDiacritics bool) ([]matchedText, int) {
+	needle := foldRunes(rawNeedle, ignoreDiacritics)
+	text := trimFolded(fold(src, ignoreDiacritics))
+	m, n := len(needle), len(text)
+	if m == 0 || m > n {
+		return nil, 0
+	}
+
+	first, last := 0, n-m
+	switch kind {
+	case termPrefix:
+		last = 0
+	case termSuffix:
+		first = n - m
+	}
+
+	best, bestScore := -1, 0
+	for i := first; i <= last; i++ {
+		if !hasFoldedPrefix(text[i:], needle) {
+			continue
+		}
+
+		prev := charWhite
+		if i > 0 {
+			prev = classOf(text[i-1].orig)
+		}
+		score := scoreMatch*m + bonusConsecutive*(m-1) + bonusFor(prev, classOf(text[i].orig))*bonusFirstCharMultiplier
+		if best < 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return nil, 0
+	}
+
+	return byteOffsets(text, []matchedText{{start: best, length: m}}), bestScore
+}
+
+func hasFoldedPrefix(text []foldedRune, prefix []rune) bool {
+	if len(prefix) > len(text) {
+		return false
+	}
+	for i, r := range prefix {
+		if text[i].r != r {
+			return false
+		}
+	}
+	return true
+}
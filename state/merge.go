@@ -0,0 +1,123 @@
+package state
+
+import (
+	"golang.org/x/exp/slices"
+)
+
+// occurrence identifies a command by its invocation and the number of earlier commands with the
+// same invocation, so that duplicate commands can be told apart.
+type occurrence struct {
+	invocation string
+	n          int
+}
+
+// occurrences indexes the commands by their occurrence of the invocation returned by key.
+// Commands for which key returns an empty invocation are skipped.
+func occurrences(commands []*Command, key func(*Command) string) map[occurrence]*Command {
+	seen := make(map[string]int)
+	index := make(map[occurrence]*Command, len(commands))
+	for _, c := range commands {
+		inv := key(c)
+		if inv == "" {
+			continue
+		}
+		index[occurrence{invocation: inv, n: seen[inv]}] = c
+		seen[inv]++
+	}
+	return index
+}
+
+// merge performs a three-way merge of the state with theirs, a version of the same file that was
+// written by another process after base was loaded. Commands are matched up by the invocation
+// they had when they were loaded, with duplicates matched in order.
+//
+// The order of commands in theirs is kept, with commands added by this process appended to the
+// end. A command deleted on either side stays deleted, and if a command was edited by this
+// process the edit takes precedence. Usage from both sides is combined.
+func (s *state) merge(base, theirs *state) {
+	invocation := func(c *Command) string { return c.Invocation }
+	baseCommands := occurrences(base.Commands, invocation)
+	ours := occurrences(s.Commands, func(c *Command) string { return c.loadedInvocation })
+
+	merged := make([]*Command, 0, len(theirs.Commands))
+	used := make(map[*Command]bool)
+	seen := make(map[string]int)
+	for _, tc := range theirs.Commands {
+		key := occurrence{invocation: tc.Invocation, n: seen[tc.Invocation]}
+		seen[tc.Invocation]++
+
+		oc, ok := ours[key]
+		if !ok {
+			// Keep commands that were added by the other process, but not those that were deleted by
+			// this one.
+			if _, inBase := baseCommands[key]; !inBase {
+				tc.state = s
+				merged = append(merged, tc)
+			}
+			continue
+		}
+
+		bc := baseCommands[key]
+		if bc == nil || sameContent(oc, bc) {
+			oc.Invocation = tc.Invocation
+			oc.Description = tc.Description
+			oc.Tags = tc.Tags
+		}
+		oc.Usage = mergeUsage(bc, oc, tc)
+
+		used[oc] = true
+		merged = append(merged, oc)
+	}
+
+	// Commands which were not yet written out were added by this process. Any other remaining
+	// commands were deleted by the other process.
+	for _, oc := range s.Commands {
+		if !used[oc] && oc.loadedInvocation == "" {
+			merged = append(merged, oc)
+		}
+	}
+	s.Commands = merged
+
+	for name, values := range theirs.Recent {
+		if s.Recent == nil {
+			s.Recent = make(map[string][]string)
+		}
+
+		recent := s.Recent[name]
+		for _, v := range values {
+			if len(recent) < maxRecentValues && !slices.Contains(recent, v) {
+				recent = append(recent, v)
+			}
+		}
+		s.Recent[name] = recent
+	}
+}
+
+// sameContent determines whether the user-editable fields of two commands are the same.
+func sameContent(a, b *Command) bool {
+	return a.Invocation == b.Invocation && a.Description == b.Description && slices.Equal(a.Tags, b.Tags)
+}
+
+// mergeUsage combines the usage recorded by two processes since base was loaded. Base may be nil if
+// the command was not in the originally loaded state.
+func mergeUsage(base, ours, theirs *Command) *Usage {
+	var baseCount int
+	if base != nil && base.Usage != nil {
+		baseCount = base.Usage.Count
+	}
+
+	if ours.Usage == nil {
+		return theirs.Usage
+	} else if theirs.Usage == nil {
+		return ours.Usage
+	}
+
+	u := &Usage{
+		Count: theirs.Usage.Count + ours.Usage.Count - baseCount,
+		Last:  theirs.Usage.Last,
+	}
+	if ours.Usage.Last.After(u.Last) {
+		u.Last = ours.Usage.Last
+	}
+	return u
+}
@@ -0,0 +1,164 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestConcurrentDumps(t *testing.T) {
+	current := time.Date(2022, time.August, 1, 12, 0, 0, 0, time.UTC)
+	defer func(orig func() time.Time) { now = orig }(now)
+	now = func() time.Time { return current }
+
+	tests := []struct {
+		msg    string
+		first  func(c *Container)
+		second func(c *Container)
+		want   []*Command
+	}{
+		{
+			msg: "both add commands",
+			first: func(c *Container) {
+				c.NewCommand("git push", "", nil) // nolint:errcheck
+			},
+			second: func(c *Container) {
+				c.NewCommand("git pull", "", nil) // nolint:errcheck
+			},
+			want: []*Command{
+				{Invocation: "git log", Usage: &Usage{Count: 1, Last: current}},
+				{Invocation: "git status"},
+				{Invocation: "git diff"},
+				{Invocation: "git pull"},
+				{Invocation: "git push"},
+			},
+		},
+		{
+			msg: "deletions on either side are kept",
+			first: func(c *Container) {
+				c.DeleteCommand(c.List()[0]) // nolint:errcheck
+			},
+			second: func(c *Container) {
+				c.DeleteCommand(c.List()[2]) // nolint:errcheck
+			},
+			want: []*Command{
+				{Invocation: "git status"},
+			},
+		},
+		{
+			msg: "edits take precedence over unmodified commands",
+			first: func(c *Container) {
+				c.UpdateCommand(c.List()[1], "git status -s", "Short status", nil) // nolint:errcheck
+			},
+			second: func(c *Container) {
+				c.UpdateCommand(c.List()[2], "git diff --staged", "", nil) // nolint:errcheck
+			},
+			want: []*Command{
+				{Invocation: "git log", Usage: &Usage{Count: 1, Last: current}},
+				{Invocation: "git status -s", Description: "Short status"},
+				{Invocation: "git diff --staged"},
+			},
+		},
+		{
+			msg: "usage is combined",
+			first: func(c *Container) {
+				c.List()[0].RecordUse()
+			},
+			second: func(c *Container) {
+				c.List()[0].RecordUse()
+				c.List()[1].RecordUse()
+			},
+			want: []*Command{
+				{Invocation: "git log", Usage: &Usage{Count: 3, Last: current}},
+				{Invocation: "git status", Usage: &Usage{Count: 1, Last: current}},
+				{Invocation: "git diff"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "speeddial.json")
+			c, err := initialize(path)
+			if err != nil {
+				t.Fatalf("Unable to initialize the container: %v", err)
+			}
+			for _, inv := range []string{"git log", "git status", "git diff"} {
+				c.NewCommand(inv, "", nil) // nolint:errcheck
+			}
+			c.List()[0].RecordUse()
+			c.Dump()
+
+			c1, err := initialize(path)
+			if err != nil {
+				t.Fatalf("Unable to initialize the first container: %v", err)
+			}
+			c2, err := initialize(path)
+			if err != nil {
+				t.Fatalf("Unable to initialize the second container: %v", err)
+			}
+
+			tt.first(c1)
+			tt.second(c2)
+			c2.Dump()
+			c1.Dump()
+
+			got, err := initialize(path)
+			if err != nil {
+				t.Fatalf("Unable to initialize the final container: %v", err)
+			}
+			if diff := cmp.Diff(got.List(), tt.want, cmpopts.IgnoreUnexported(Command{})); diff != "" {
+				t.Errorf("Unexpected commands after merging (-got, +want):\n%s", diff)
+			}
+
+			matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp-*"))
+			if err != nil || len(matches) > 0 {
+				t.Errorf("Temporary files were left behind: %v (%v)", matches, err)
+			}
+		})
+	}
+}
+
+func TestConcurrentDumpsWithDuplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "speeddial.json")
+	c, err := initialize(path)
+	if err != nil {
+		t.Fatalf("Unable to initialize the container: %v", err)
+	}
+	c.NewCommand("ls", "First", nil)  // nolint:errcheck
+	c.NewCommand("pwd", "", nil)      // nolint:errcheck
+	c.NewCommand("ls", "Second", nil) // nolint:errcheck
+	c.Dump()
+
+	c1, err := initialize(path)
+	if err != nil {
+		t.Fatalf("Unable to initialize the first container: %v", err)
+	}
+	c2, err := initialize(path)
+	if err != nil {
+		t.Fatalf("Unable to initialize the second container: %v", err)
+	}
+
+	c1.UpdateCommand(c1.List()[2], "ls", "Second, edited", nil) // nolint:errcheck
+	c2.NewCommand("ls", "Third", nil)                           // nolint:errcheck
+	c2.Dump()
+	c1.Dump()
+
+	got, err := initialize(path)
+	if err != nil {
+		t.Fatalf("Unable to initialize the final container: %v", err)
+	}
+	want := []*Command{
+		{Invocation: "ls", Description: "First"},
+		{Invocation: "pwd"},
+		{Invocation: "ls", Description: "Second, edited"},
+		{Invocation: "ls", Description: "Third"},
+	}
+	if diff := cmp.Diff(got.List(), want, cmpopts.IgnoreUnexported(Command{})); diff != "" {
+		t.Errorf("Unexpected commands after merging (-got, +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,150 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// migration upgrades the data of a dump by a single version, along with notes for the user about
+// any data that could not be kept. Migrations operate on the raw JSON so that they do not depend
+// on the current structure of state.
+type migration func(data json.RawMessage) (json.RawMessage, []string, error)
+
+// migrations maps each version to the migration that upgrades a dump of that version to the next
+// one. Every version below currentDumpVersion must have an entry.
+var migrations = map[int]migration{
+	dumpVersion1: migrateV1,
+}
+
+// rawDump is a dump whose data has not yet been decoded.
+type rawDump struct {
+	Version int             `json:"v"`
+	Data    json.RawMessage `json:"d"`
+}
+
+// migrate upgrades the raw contents of a state file to the current version, returning the
+// upgraded contents along with the version that the file was originally at and the notes from
+// every migration. If no migration is necessary, the contents are returned unchanged.
+func migrate(raw []byte, path string) ([]byte, int, []string, error) {
+	var d rawDump
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return nil, 0, nil, err
+	}
+
+	version := d.Version
+	if version < dumpVersion1 {
+		return nil, version, nil, fmt.Errorf("%d is an unsupported version for state at %s", version, path)
+	} else if version > currentDumpVersion {
+		return nil, version, nil, fmt.Errorf("the state at %s was written by a newer version of speeddial (state version %d, but at most %d is supported): please upgrade speeddial", path, version, currentDumpVersion)
+	} else if version == currentDumpVersion {
+		return raw, version, nil, nil
+	}
+
+	if len(d.Data) == 0 || string(d.Data) == "null" {
+		return nil, version, nil, fmt.Errorf("dump at %s does not have any state", path)
+	}
+
+	var notes []string
+	for v := version; v < currentDumpVersion; v++ {
+		m, ok := migrations[v]
+		if !ok {
+			return nil, version, nil, fmt.Errorf("there is no migration from version %d of the state", v)
+		}
+
+		data, n, err := m(d.Data)
+		if err != nil {
+			return nil, version, nil, fmt.Errorf("unable to migrate the state at %s from version %d to %d: %v", path, v, v+1, err)
+		}
+		d.Data = data
+		notes = append(notes, n...)
+	}
+	d.Version = currentDumpVersion
+
+	upgraded, err := json.Marshal(&d)
+	if err != nil {
+		return nil, version, nil, err
+	}
+	return append(upgraded, '\n'), version, notes, nil
+}
+
+// backupFile copies the file at path to a new backup file next to it, returning the path of the
+// backup. Existing backups are never overwritten.
+func backupFile(path string, raw []byte, version int) (string, error) {
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	for i := 1; ; i++ {
+		_, err := os.Stat(backup)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		backup = fmt.Sprintf("%s.v%d.bak.%d", path, version, i)
+	}
+
+	return backup, os.WriteFile(backup, raw, 0o600)
+}
+
+// migrateV1 upgrades version 1 to version 2. Version 2 stores invocations without surrounding
+// whitespace (which was previously captured from some shells' history) and does not allow empty
+// invocations, so commands with empty invocations are dropped. Commands in version 2 may also have
+// tags and usage.
+func migrateV1(data json.RawMessage) (json.RawMessage, []string, error) {
+	var s map[string]json.RawMessage
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, nil, err
+	}
+
+	var commands []map[string]json.RawMessage
+	if raw, ok := s["c"]; ok {
+		if err := json.Unmarshal(raw, &commands); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	migrated := make([]map[string]json.RawMessage, 0, len(commands))
+	dropped := 0
+	for _, c := range commands {
+		if c == nil {
+			continue
+		}
+
+		var inv string
+		if raw, ok := c["i"]; ok {
+			if err := json.Unmarshal(raw, &inv); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		inv = strings.TrimSpace(inv)
+		if inv == "" {
+			dropped++
+			continue
+		}
+
+		raw, err := json.Marshal(inv)
+		if err != nil {
+			return nil, nil, err
+		}
+		c["i"] = raw
+		migrated = append(migrated, c)
+	}
+
+	raw, err := json.Marshal(migrated)
+	if err != nil {
+		return nil, nil, err
+	}
+	s["c"] = raw
+
+	var notes []string
+	if dropped == 1 {
+		notes = append(notes, "dropped 1 command with an empty invocation")
+	} else if dropped > 1 {
+		notes = append(notes, fmt.Sprintf("dropped %d commands with an empty invocation", dropped))
+	}
+
+	data, err = json.Marshal(s)
+	return data, notes, err
+}
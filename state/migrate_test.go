@@ -0,0 +1,211 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestMigrations loads every fixture in testdata/migrations (named v<version>.json) and checks
+// that it is migrated to the contents of the corresponding v<version>.want.json file.
+func TestMigrations(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "migrations", "v*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tested := make(map[int]bool)
+	for _, fixture := range fixtures {
+		if strings.HasSuffix(fixture, ".want.json") {
+			continue
+		}
+
+		fixture := fixture
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			orig, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(strings.TrimSuffix(fixture, ".json") + ".want.json")
+			if err != nil {
+				t.Fatalf("Unable to read the expected output for the fixture: %v", err)
+			}
+
+			var d rawDump
+			if err := json.Unmarshal(orig, &d); err != nil {
+				t.Fatal(err)
+			}
+			tested[d.Version] = true
+
+			path := filepath.Join(t.TempDir(), "speeddial.json")
+			if err := os.WriteFile(path, orig, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := initialize(path); err != nil {
+				t.Fatalf("Unable to load the fixture: %v", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(decodeJSON(t, got), decodeJSON(t, want)); diff != "" {
+				t.Errorf("Migrated state diff (-got, +want):\n%s", diff)
+			}
+
+			backup, err := os.ReadFile(fmt.Sprintf("%s.v%d.bak", path, d.Version))
+			if err != nil {
+				t.Fatalf("Unable to read the backup: %v", err)
+			}
+			if string(backup) != string(orig) {
+				t.Errorf("The backup does not match the original file")
+			}
+
+			// Loading the migrated file again should not create another backup
+			if _, err := initialize(path); err != nil {
+				t.Fatalf("Unable to load the migrated file: %v", err)
+			}
+			if backups, _ := filepath.Glob(path + ".*.bak*"); len(backups) != 1 {
+				t.Errorf("Expected exactly one backup, found %v", backups)
+			}
+		})
+	}
+
+	for v := dumpVersion1; v < currentDumpVersion; v++ {
+		if _, ok := migrations[v]; !ok {
+			t.Errorf("There is no migration from version %d", v)
+		}
+		if !tested[v] {
+			t.Errorf("There is no fixture for version %d", v)
+		}
+	}
+}
+
+func TestReadOnlyMigration(t *testing.T) {
+	orig, err := os.ReadFile(filepath.Join("testdata", "migrations", "v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "migrations", "v1.want.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "speeddial.json")
+	if err := os.WriteFile(path, orig, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Container{}
+	if err := c.Load(Source{Name: "team", Path: path}); err != nil {
+		t.Fatalf("Unable to load the fixture as a read-only source: %v", err)
+	}
+
+	// The state is migrated in memory, but the file itself is left alone
+	got, err := encodeState(c.source("team"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(decodeJSON(t, got), decodeJSON(t, want)); diff != "" {
+		t.Errorf("Migrated state diff (-got, +want):\n%s", diff)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(orig) {
+		t.Errorf("A read-only source was rewritten by its migration")
+	}
+	if backups, _ := filepath.Glob(path + ".*.bak*"); len(backups) != 0 {
+		t.Errorf("Expected no backups of a read-only source, found %v", backups)
+	}
+}
+
+func TestMigrationNotes(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{content: `{"v":1,"d":{"c":[{"i":"ls"}]}}`},
+		{content: `{"v":1,"d":{"c":[{"i":"ls"},{"i":"  "}]}}`, want: []string{"dropped 1 command with an empty invocation"}},
+		{content: `{"v":1,"d":{"c":[{"i":""},{"d":"No invocation"},{"i":"ls"}]}}`, want: []string{"dropped 2 commands with an empty invocation"}},
+		{content: `{"v":2,"d":{"c":[{"i":""}]}}`},
+	}
+
+	for _, tt := range tests {
+		_, _, notes, err := migrate([]byte(tt.content), "speeddial.json")
+		if err != nil {
+			t.Errorf("Unable to migrate %s: %v", tt.content, err)
+			continue
+		}
+		if diff := cmp.Diff(notes, tt.want); diff != "" {
+			t.Errorf("Notes when migrating %s diff (-got, +want):\n%s", tt.content, diff)
+		}
+	}
+}
+
+func TestMigrationErrors(t *testing.T) {
+	tests := []struct {
+		msg     string
+		content string
+		errText string
+	}{
+		{
+			msg:     "newer version",
+			content: `{"v":1000,"d":{"c":[]}}`,
+			errText: "newer version of speeddial",
+		},
+		{
+			msg:     "unsupported version",
+			content: `{"v":0,"d":{"c":[]}}`,
+			errText: "unsupported version",
+		},
+		{
+			msg:     "missing state",
+			content: `{"v":1}`,
+			errText: "does not have any state",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "speeddial.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := initialize(path)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected an error containing %q, got %v", tt.errText, err)
+			}
+
+			// The file should be left untouched
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.content {
+				t.Errorf("The file was modified despite the error")
+			}
+		})
+	}
+}
+
+func decodeJSON(t *testing.T, raw []byte) any {
+	t.Helper()
+
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		t.Fatalf("Unable to decode %q: %v", raw, err)
+	}
+	return v
+}
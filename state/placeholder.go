@@ -0,0 +1,102 @@
+package state
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// The number of recent values that are remembered for each placeholder.
+	maxRecentValues = 10
+)
+
+// placeholderExpr matches placeholders of the form <<name>> or <<name=default>>. Names start with
+// a letter or underscore and are written right inside the delimiters, so that shell code such as
+// "cat <<EOF >>log" or "$((1<<2>>1))" is not mistaken for a placeholder.
+var placeholderExpr = regexp.MustCompile(`<<([A-Za-z_][A-Za-z0-9_.-]*)(?:=(.*?))?>>`)
+
+// Placeholder is a named value in an invocation that has to be filled in before the command can
+// be run. Placeholders are written as <<name>> or, with a default value, as <<name=default>>.
+type Placeholder struct {
+	Name    string
+	Default string
+}
+
+// Placeholders returns the placeholders in the command's invocation in the order in which they
+// first appear. If a placeholder is used multiple times, the first default that is specified
+// is used. Whitespace around defaults is ignored.
+func (c *Command) Placeholders() []Placeholder {
+	var placeholders []Placeholder
+	indices := make(map[string]int)
+
+	for _, m := range placeholderExpr.FindAllStringSubmatch(c.Invocation, -1) {
+		name, def := m[1], strings.TrimSpace(m[2])
+		if i, ok := indices[name]; ok {
+			if placeholders[i].Default == "" {
+				placeholders[i].Default = def
+			}
+			continue
+		}
+
+		indices[name] = len(placeholders)
+		placeholders = append(placeholders, Placeholder{Name: name, Default: def})
+	}
+
+	return placeholders
+}
+
+// Fill returns the command's invocation with every placeholder replaced by the corresponding
+// entry in values. Placeholders without a (non-empty) value fall back to their default.
+func (c *Command) Fill(values map[string]string) string {
+	defaults := make(map[string]string)
+	for _, p := range c.Placeholders() {
+		defaults[p.Name] = p.Default
+	}
+
+	return placeholderExpr.ReplaceAllStringFunc(c.Invocation, func(s string) string {
+		name := placeholderExpr.FindStringSubmatch(s)[1]
+		if v := values[name]; v != "" {
+			return v
+		}
+		return defaults[name]
+	})
+}
+
+// RecentValues returns the values most recently used for the given placeholder, with the most
+// recent value first.
+func (c *Container) RecentValues(name string) []string {
+	if s := c.source(PersonalSource); s != nil {
+		return s.Recent[name]
+	}
+	return nil
+}
+
+// RememberValues records the given placeholder values in the personal state so that they can be
+// suggested in the future.
+func (c *Container) RememberValues(values map[string]string) {
+	s := c.source(PersonalSource)
+	if s == nil {
+		return
+	}
+
+	if s.Recent == nil {
+		s.Recent = make(map[string][]string)
+	}
+	for name, v := range values {
+		if v == "" {
+			continue
+		}
+
+		recent := s.Recent[name]
+		if i := slices.Index(recent, v); i >= 0 {
+			recent = slices.Delete(recent, i, i+1)
+		}
+		recent = append([]string{v}, recent...)
+		if len(recent) > maxRecentValues {
+			recent = recent[:maxRecentValues]
+		}
+		s.Recent[name] = recent
+	}
+}
@@ -0,0 +1,123 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		msg          string
+		invocation   string
+		values       map[string]string
+		placeholders []Placeholder
+		filled       string
+	}{
+		{
+			msg:        "no placeholders",
+			invocation: "git log --graph",
+			filled:     "git log --graph",
+		},
+		{
+			msg:        "single placeholder",
+			invocation: "git checkout <<branch>>",
+			values:     map[string]string{"branch": "main"},
+			placeholders: []Placeholder{
+				{Name: "branch"},
+			},
+			filled: "git checkout main",
+		},
+		{
+			msg:        "multiple placeholders with defaults",
+			invocation: "kubectl -n <<namespace=default>> logs <<pod>>",
+			values:     map[string]string{"pod": "api-0"},
+			placeholders: []Placeholder{
+				{Name: "namespace", Default: "default"},
+				{Name: "pod"},
+			},
+			filled: "kubectl -n default logs api-0",
+		},
+		{
+			msg:        "repeated placeholder",
+			invocation: "cp <<file>> <<file=a.txt>>.bak",
+			values:     map[string]string{"file": "b.txt"},
+			placeholders: []Placeholder{
+				{Name: "file", Default: "a.txt"},
+			},
+			filled: "cp b.txt b.txt.bak",
+		},
+		{
+			msg:        "whitespace and special characters in the default",
+			invocation: "ssh <<host= user@example.com >>",
+			placeholders: []Placeholder{
+				{Name: "host", Default: "user@example.com"},
+			},
+			filled: "ssh user@example.com",
+		},
+		{
+			msg:        "heredocs and appending redirections",
+			invocation: "cat <<EOF >>log",
+			filled:     "cat <<EOF >>log",
+		},
+		{
+			msg:        "bit shifts",
+			invocation: "echo $((1<<2>>1))",
+			filled:     "echo $((1<<2>>1))",
+		},
+		{
+			msg:        "whitespace inside the delimiters",
+			invocation: "echo << name >>",
+			filled:     "echo << name >>",
+		},
+		{
+			msg:        "empty values fall back to the default",
+			invocation: "git log <<ref=HEAD>>..<<other>>",
+			values:     map[string]string{"ref": "", "other": "main"},
+			placeholders: []Placeholder{
+				{Name: "ref", Default: "HEAD"},
+				{Name: "other"},
+			},
+			filled: "git log HEAD..main",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			c := &Command{Invocation: tt.invocation}
+
+			if diff := cmp.Diff(c.Placeholders(), tt.placeholders); diff != "" {
+				t.Errorf("[]Placeholder diff (-got, +want):\n%s", diff)
+			}
+			if got := c.Fill(tt.values); got != tt.filled {
+				t.Errorf("Fill returned %q, want %q", got, tt.filled)
+			}
+		})
+	}
+}
+
+func TestRememberValues(t *testing.T) {
+	c, err := initialize(filepath.Join(t.TempDir(), "speeddial.json"))
+	if err != nil {
+		t.Fatalf("Unable to initialize the container: %v", err)
+	}
+
+	c.RememberValues(map[string]string{"branch": "main", "pod": ""})
+	c.RememberValues(map[string]string{"branch": "dev"})
+	c.RememberValues(map[string]string{"branch": "main"})
+	for i := 0; i < maxRecentValues+5; i++ {
+		c.RememberValues(map[string]string{"ns": string(rune('a' + i))})
+	}
+
+	if diff := cmp.Diff(c.RecentValues("branch"), []string{"main", "dev"}); diff != "" {
+		t.Errorf("Recent values diff (-got, +want):\n%s", diff)
+	}
+	if got := c.RecentValues("pod"); got != nil {
+		t.Errorf("Empty values should not be remembered, got %v", got)
+	}
+	if got := c.RecentValues("ns"); len(got) != maxRecentValues || got[0] != "o" {
+		t.Errorf("Recent values were not capped correctly, got %v", got)
+	}
+}
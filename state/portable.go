@@ -0,0 +1,265 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats supported for exports and imports. Markdown can only be exported.
+const (
+	FormatJSON     = "json"
+	FormatYAML     = "yaml"
+	FormatTOML     = "toml"
+	FormatMarkdown = "markdown"
+)
+
+// Ways to group commands in Markdown exports.
+const (
+	GroupByTag    = "tag"
+	GroupBySource = "source"
+)
+
+// portableVersion is the version of the portable format, which is independent of the version of the
+// on-disk state.
+const portableVersion = 1
+
+// PortableCommand is the representation of a command in exports and imports. Unlike the on-disk
+// format, it uses descriptive keys so that it is easy to read and review.
+type PortableCommand struct {
+	Invocation  string     `json:"invocation" yaml:"invocation" toml:"invocation"`
+	Description string     `json:"description,omitempty" yaml:"description,omitempty" toml:"description,omitempty"`
+	Tags        []string   `json:"tags,omitempty" yaml:"tags,omitempty" toml:"tags,omitempty"`
+	Uses        int        `json:"uses,omitempty" yaml:"uses,omitempty" toml:"uses,omitempty"`
+	LastUsed    *time.Time `json:"last_used,omitempty" yaml:"last_used,omitempty" toml:"last_used,omitempty"`
+}
+
+type portableDocument struct {
+	Version  int               `json:"version" yaml:"version" toml:"version"`
+	Commands []PortableCommand `json:"commands" yaml:"commands" toml:"commands"`
+}
+
+// EntryError describes an invalid entry in an imported file.
+type EntryError struct {
+	// The zero-based position of the entry in the file.
+	Index int
+	Err   error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("entry %d: %v", e.Index+1, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// FormatFromPath guesses the format of a file from its extension, returning an empty string if
+// the extension is not recognized.
+func FormatFromPath(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".json"):
+		return FormatJSON
+	case strings.HasSuffix(path, ".yaml"), strings.HasSuffix(path, ".yml"):
+		return FormatYAML
+	case strings.HasSuffix(path, ".toml"):
+		return FormatTOML
+	case strings.HasSuffix(path, ".md"), strings.HasSuffix(path, ".markdown"):
+		return FormatMarkdown
+	}
+	return ""
+}
+
+// Export writes the given commands to w in the given format. The groupBy parameter is only used for
+// Markdown exports.
+func Export(w io.Writer, format, groupBy string, commands []*Command) error {
+	if format == FormatMarkdown {
+		return exportMarkdown(w, groupBy, commands)
+	}
+
+	doc := portableDocument{
+		Version:  portableVersion,
+		Commands: make([]PortableCommand, 0, len(commands)),
+	}
+	for _, c := range commands {
+		pc := PortableCommand{
+			Invocation:  c.Invocation,
+			Description: c.Description,
+			Tags:        c.Tags,
+		}
+		if c.Usage != nil {
+			last := c.Usage.Last
+			pc.Uses = c.Usage.Count
+			pc.LastUsed = &last
+		}
+		doc.Commands = append(doc.Commands, pc)
+	}
+
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&doc)
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(&doc); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatTOML:
+		return toml.NewEncoder(w).Encode(&doc)
+	}
+
+	return fmt.Errorf("%q is not a supported export format", format)
+}
+
+// Import reads commands in the given format from r. Entries which are invalid are skipped and
+// described in the returned slice of errors, while the returned error is only set if the input
+// could not be decoded at all. Markdown cannot be imported.
+func Import(r io.Reader, format string) ([]*Command, []error, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var doc portableDocument
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&doc)
+	case FormatYAML:
+		dec := yaml.NewDecoder(bytes.NewReader(raw))
+		dec.KnownFields(true)
+		err = dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case FormatTOML:
+		var md toml.MetaData
+		md, err = toml.Decode(string(raw), &doc)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys: %v", md.Undecoded())
+		}
+	default:
+		return nil, nil, fmt.Errorf("%q is not a supported import format", format)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to decode the input as %s: %v", format, err)
+	}
+
+	if doc.Version > portableVersion {
+		return nil, nil, fmt.Errorf("version %d of the export format is newer than the supported version %d", doc.Version, portableVersion)
+	}
+
+	var commands []*Command
+	var entryErrs []error
+	for i, pc := range doc.Commands {
+		c, err := pc.command()
+		if err != nil {
+			entryErrs = append(entryErrs, &EntryError{Index: i, Err: err})
+			continue
+		}
+		commands = append(commands, c)
+	}
+
+	return commands, entryErrs, nil
+}
+
+// command validates the portable command and converts it into a Command.
+func (pc *PortableCommand) command() (*Command, error) {
+	if strings.TrimSpace(pc.Invocation) == "" {
+		return nil, errors.New("the invocation is missing")
+	} else if pc.Uses < 0 {
+		return nil, fmt.Errorf("the number of uses (%d) cannot be negative", pc.Uses)
+	} else if pc.Uses == 0 && pc.LastUsed != nil {
+		return nil, errors.New("a last used time was given for a command without any uses")
+	}
+
+	c := &Command{
+		Invocation:  pc.Invocation,
+		Description: pc.Description,
+		Tags:        NormalizeTags(pc.Tags),
+	}
+	if pc.Uses > 0 {
+		c.Usage = &Usage{Count: pc.Uses}
+		if pc.LastUsed != nil {
+			c.Usage.Last = pc.LastUsed.UTC()
+		}
+	}
+	return c, nil
+}
+
+// exportMarkdown writes out a cheat sheet of the commands, grouped by tag or by source. Commands
+// with multiple tags are listed under each of them.
+func exportMarkdown(w io.Writer, groupBy string, commands []*Command) error {
+	const ungrouped = "Other"
+
+	groups := make(map[string][]*Command)
+	for _, c := range commands {
+		var keys []string
+		switch groupBy {
+		case GroupByTag:
+			keys = c.Tags
+		case GroupBySource:
+			if src := c.Source(); src != "" {
+				keys = []string{src}
+			}
+		default:
+			return fmt.Errorf("%q is not a supported way to group commands", groupBy)
+		}
+
+		if len(keys) == 0 {
+			keys = []string{ungrouped}
+		}
+		for _, k := range keys {
+			groups[k] = append(groups[k], c)
+		}
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		if name != ungrouped {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := groups[ungrouped]; ok {
+		names = append(names, ungrouped)
+	}
+
+	var b strings.Builder
+	b.WriteString("# Speeddial Commands\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n## %s\n", name)
+		for _, c := range groups[name] {
+			b.WriteString("\n")
+			if c.Description != "" {
+				fmt.Fprintf(&b, "%s\n\n", c.Description)
+			}
+
+			// The fence has to be longer than any run of backticks in the invocation
+			fence := "```"
+			for strings.Contains(c.Invocation, fence) {
+				fence += "`"
+			}
+			fmt.Fprintf(&b, "%ssh\n%s\n%s\n", fence, c.Invocation, fence)
+
+			if groupBy != GroupByTag && len(c.Tags) > 0 {
+				fmt.Fprintf(&b, "\nTags: %s\n", c.tagString())
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
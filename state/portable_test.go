@@ -0,0 +1,167 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	commands := []*Command{
+		{
+			Invocation:  "kubectl -n <<namespace=default>> logs <<pod>>",
+			Description: "Follow logs",
+			Tags:        []string{"k8s", "logs"},
+			Usage:       &Usage{Count: 3, Last: time.Date(2022, time.August, 1, 12, 30, 0, 0, time.UTC)},
+		},
+		{Invocation: "echo \"quotes\" and 'more' | grep -v \\n", Description: "Special characters"},
+		{Invocation: "for f in *; do\n  echo $f\ndone"},
+	}
+
+	for _, format := range []string{FormatJSON, FormatYAML, FormatTOML} {
+		format := format
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Export(&buf, format, "", commands); err != nil {
+				t.Fatalf("Unable to export the commands: %v", err)
+			}
+
+			got, entryErrs, err := Import(&buf, format)
+			if err != nil {
+				t.Fatalf("Unable to import the commands: %v", err)
+			}
+			if len(entryErrs) > 0 {
+				t.Errorf("Unexpected entry errors: %v", entryErrs)
+			}
+
+			if diff := cmp.Diff(got, commands, cmpopts.IgnoreUnexported(Command{})); diff != "" {
+				t.Errorf("Round-tripped commands diff (-got, +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestImportValidation(t *testing.T) {
+	tests := []struct {
+		msg         string
+		format      string
+		input       string
+		invocations []string
+		entryErrs   []int
+		wantErr     bool
+	}{
+		{
+			msg:         "valid yaml with tags that need normalizing",
+			format:      FormatYAML,
+			input:       "version: 1\ncommands:\n  - invocation: ls\n    tags: ['#Files', files]\n",
+			invocations: []string{"ls"},
+		},
+		{
+			msg:         "invalid entries are reported",
+			format:      FormatJSON,
+			input:       `{"version": 1, "commands": [{"invocation": ""}, {"invocation": "ls"}, {"invocation": "pwd", "uses": -1}, {"invocation": "cd", "last_used": "2022-08-01T00:00:00Z"}]}`,
+			invocations: []string{"ls"},
+			entryErrs:   []int{0, 2, 3},
+		},
+		{
+			msg:     "unknown keys",
+			format:  FormatTOML,
+			input:   "version = 1\n[[commands]]\ninvocation = \"ls\"\ncolour = \"red\"\n",
+			wantErr: true,
+		},
+		{
+			msg:     "newer version",
+			format:  FormatJSON,
+			input:   `{"version": 2, "commands": []}`,
+			wantErr: true,
+		},
+		{
+			msg:     "malformed input",
+			format:  FormatYAML,
+			input:   "commands: [",
+			wantErr: true,
+		},
+		{
+			msg:     "markdown cannot be imported",
+			format:  FormatMarkdown,
+			input:   "# Speeddial Commands\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			commands, entryErrs, err := Import(strings.NewReader(tt.input), tt.format)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error when importing")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("Unable to import: %v", err)
+			}
+
+			var invocations []string
+			for _, c := range commands {
+				invocations = append(invocations, c.Invocation)
+			}
+			if diff := cmp.Diff(invocations, tt.invocations); diff != "" {
+				t.Errorf("Imported invocations diff (-got, +want):\n%s", diff)
+			}
+
+			var indices []int
+			for _, err := range entryErrs {
+				var entryErr *EntryError
+				if !errors.As(err, &entryErr) {
+					t.Fatalf("Entry error %v is not an *EntryError", err)
+				}
+				indices = append(indices, entryErr.Index)
+			}
+			if diff := cmp.Diff(indices, tt.entryErrs); diff != "" {
+				t.Errorf("Entry error indices diff (-got, +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	team := &state{name: "team"}
+	commands := []*Command{
+		{Invocation: "kubectl get pods", Description: "List pods", Tags: []string{"k8s"}, state: team},
+		{Invocation: "echo ```", Tags: []string{"shell", "k8s"}},
+		{Invocation: "ls"},
+	}
+
+	byTag := "# Speeddial Commands\n" +
+		"\n## k8s\n" +
+		"\nList pods\n\n```sh\nkubectl get pods\n```\n" +
+		"\n````sh\necho ```\n````\n" +
+		"\n## shell\n" +
+		"\n````sh\necho ```\n````\n" +
+		"\n## Other\n" +
+		"\n```sh\nls\n```\n"
+
+	bySource := "# Speeddial Commands\n" +
+		"\n## team\n" +
+		"\nList pods\n\n```sh\nkubectl get pods\n```\n\nTags: #k8s\n" +
+		"\n## Other\n" +
+		"\n````sh\necho ```\n````\n\nTags: #shell #k8s\n" +
+		"\n```sh\nls\n```\n"
+
+	for groupBy, want := range map[string]string{GroupByTag: byTag, GroupBySource: bySource} {
+		var buf bytes.Buffer
+		if err := Export(&buf, FormatMarkdown, groupBy, commands); err != nil {
+			t.Fatalf("Unable to export Markdown grouped by %s: %v", groupBy, err)
+		}
+
+		if diff := cmp.Diff(buf.String(), want); diff != "" {
+			t.Errorf("Markdown grouped by %s diff (-got, +want):\n%s", groupBy, diff)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rithvikp/speeddial/term"
+)
+
+// Preview returns the full details of the command for the picker's preview pane. It conforms to
+// the term.Previewer interface.
+func (c *Command) Preview() []term.PreviewField {
+	fields := []term.PreviewField{
+		{Label: "Invocation", Value: c.Invocation},
+		{Label: "Description", Value: c.Description},
+		{Label: "Tags", Value: c.tagString()},
+	}
+
+	if c.state != nil {
+		fields = append(fields, term.PreviewField{Label: "Source", Value: fmt.Sprintf("%s (%s)", c.state.name, c.state.path)})
+	}
+
+	if c.Usage != nil && c.Usage.Count > 0 {
+		fields = append(fields, term.PreviewField{
+			Label: "Usage",
+			Value: fmt.Sprintf("Used %d time(s), last on %s", c.Usage.Count, c.Usage.Last.Local().Format("2006-01-02 15:04")),
+		})
+	}
+
+	var placeholders []string
+	for _, p := range c.Placeholders() {
+		if p.Default != "" {
+			placeholders = append(placeholders, fmt.Sprintf("%s (default: %s)", p.Name, p.Default))
+		} else {
+			placeholders = append(placeholders, p.Name)
+		}
+	}
+	fields = append(fields, term.PreviewField{Label: "Placeholders", Value: strings.Join(placeholders, ", ")})
+
+	return fields
+}
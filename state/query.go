@@ -0,0 +1,316 @@
+package state
+
+import (
+	"strings"
+	"unicode"
+)
+
+// queryField is the part of a command that a query term is matched against.
+type queryField int
+
+const (
+	// fieldAny matches either the invocation or the description.
+	fieldAny queryField = iota
+	fieldInvocation
+	fieldDescription
+	fieldSource
+	fieldTag
+)
+
+// fieldPrefixes maps the prefixes that scope a term to a field, e.g. inv:docker.
+var fieldPrefixes = []struct {
+	prefix string
+	field  queryField
+}{
+	{prefix: "inv:", field: fieldInvocation},
+	{prefix: "desc:", field: fieldDescription},
+	{prefix: "src:", field: fieldSource},
+	{prefix: "tag:", field: fieldTag},
+	{prefix: "#", field: fieldTag},
+}
+
+// termKind is how the text of a query term is matched.
+type termKind int
+
+const (
+	termFuzzy termKind = iota
+	termExact
+	termPrefix
+	termSuffix
+)
+
+// queryTerm is a single term of a query, such as !inv:^sudo.
+type queryTerm struct {
+	field  queryField
+	kind   termKind
+	text   string
+	negate bool
+}
+
+// query is a parsed search query. Commands match a query if they fuzzy match its cleaned text,
+// have all of its tags and match at least one term in each of its groups.
+//
+// The query language is modelled on fzf's extended search. Terms are separated by whitespace and
+// each one is made up of, in order:
+//
+//	!        (optional) negates the term: commands that match it are excluded
+//	field:   (optional) one of inv:, desc:, src: or tag: (or #), which restricts the term to
+//	         that part of the command
+//	' ^ $    (optional) an exact (substring), prefix or suffix match instead of a fuzzy one. An
+//	         exact match that is closed with another ' can contain spaces, e.g. 'git log'
+//	text
+//
+// Terms separated by a standalone | form a group, which matches if any of its terms match. Tag
+// terms match tags that they are a prefix of unless they are exact (the whole tag) or suffix
+// terms, and negated terms that would be fuzzy are exact instead, as in fzf.
+type query struct {
+	// Whether accented characters match their base characters (e.g. "e" matches "é").
+	ignoreDiacritics bool
+
+	raw string
+	// The plain terms of the query (unscoped fuzzy terms outside of groups) joined by spaces,
+	// which are fuzzy matched together against the invocation and description.
+	cleaned string
+	// Tags that every matched command must have, parsed from terms like #tag or tag:tag.
+	tags []string
+	// Every other term, each in a group of alternatives.
+	groups [][]queryTerm
+}
+
+// parseQuery parses the raw query (see query). Terms without any text, such as a lone "!" while
+// the user is still typing, are ignored.
+func parseQuery(raw string) *query {
+	q := query{
+		raw: raw,
+	}
+
+	var plain []string
+	var group []queryTerm
+	or := false
+	for _, tok := range tokenizeQuery(strings.ToLower(raw)) {
+		if tok == "|" {
+			or = len(group) > 0
+			continue
+		}
+
+		t, ok := parseTerm(tok)
+		if !ok {
+			continue
+		}
+		if or {
+			group = append(group, t)
+			or = false
+			continue
+		}
+
+		q.addGroup(group, &plain)
+		group = []queryTerm{t}
+	}
+	q.addGroup(group, &plain)
+
+	q.cleaned = strings.Join(plain, " ")
+	return &q
+}
+
+// parseModeQuery parses the raw query for the given search mode. In regex mode, only the raw query
+// is used.
+func parseModeQuery(raw string, mode SearchMode) *query {
+	switch mode {
+	case ExactMode:
+		q := parseQuery(raw)
+		for _, g := range q.groups {
+			for i := range g {
+				if g[i].kind == termFuzzy {
+					g[i].kind = termExact
+				}
+			}
+		}
+		for _, text := range strings.Fields(q.cleaned) {
+			q.groups = append(q.groups, []queryTerm{{kind: termExact, text: text}})
+		}
+		q.cleaned = ""
+		return q
+
+	case PrefixMode:
+		q := &query{raw: raw}
+		if text := strings.TrimSpace(strings.ToLower(raw)); text != "" {
+			q.groups = [][]queryTerm{{{kind: termPrefix, text: text}}}
+		}
+		return q
+	}
+
+	return parseQuery(raw)
+}
+
+// addGroup adds a group of terms to the query. Groups with a single term that is a plain fuzzy
+// term or a tag are added to the cleaned text or tags respectively.
+func (q *query) addGroup(group []queryTerm, plain *[]string) {
+	if len(group) == 0 {
+		return
+	}
+
+	if len(group) == 1 && !group[0].negate {
+		switch t := group[0]; {
+		case t.field == fieldAny && t.kind == termFuzzy:
+			*plain = append(*plain, t.text)
+			return
+		case t.field == fieldTag && t.kind == termPrefix:
+			q.tags = append(q.tags, t.text)
+			return
+		}
+	}
+	q.groups = append(q.groups, group)
+}
+
+// tokenizeQuery splits the query into terms, keeping closed exact phrases (e.g. 'git log') in a
+// single term.
+func tokenizeQuery(raw string) []string {
+	var tokens []string
+	for {
+		raw = strings.TrimLeftFunc(raw, unicode.IsSpace)
+		if raw == "" {
+			return tokens
+		}
+
+		end := strings.IndexFunc(raw, unicode.IsSpace)
+		if end < 0 {
+			end = len(raw)
+		}
+
+		// Look past any modifiers for the start of a phrase
+		rest := strings.TrimPrefix(raw, "!")
+		for _, fp := range fieldPrefixes {
+			if strings.HasPrefix(rest, fp.prefix) {
+				rest = rest[len(fp.prefix):]
+				break
+			}
+		}
+		if strings.HasPrefix(rest, "'") {
+			if i := phraseEnd(raw, len(raw)-len(rest)+1); i >= 0 {
+				end = i
+			}
+		}
+
+		tokens = append(tokens, raw[:end])
+		raw = raw[end:]
+	}
+}
+
+// phraseEnd returns the end of the exact phrase starting at the given offset, which is just after
+// the first quote that is followed by whitespace or the end of the query, or -1 if the phrase is
+// not closed.
+func phraseEnd(raw string, start int) int {
+	for i := start; i < len(raw); i++ {
+		if raw[i] != '\'' {
+			continue
+		}
+		if next := raw[i+1:]; next == "" || unicode.IsSpace(rune(next[0])) {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// parseTerm parses a single term, returning false if the term does not have any text.
+func parseTerm(tok string) (queryTerm, bool) {
+	var t queryTerm
+	if strings.HasPrefix(tok, "!") {
+		t.negate = true
+		tok = tok[1:]
+	}
+
+	for _, fp := range fieldPrefixes {
+		if strings.HasPrefix(tok, fp.prefix) {
+			t.field = fp.field
+			tok = tok[len(fp.prefix):]
+			break
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(tok, "'"):
+		t.kind = termExact
+		tok = strings.TrimSuffix(tok[1:], "'")
+	case strings.HasPrefix(tok, "^"):
+		t.kind = termPrefix
+		tok = tok[1:]
+	case strings.HasPrefix(tok, "$"):
+		t.kind = termSuffix
+		tok = tok[1:]
+	case t.field == fieldTag:
+		t.kind = termPrefix
+	case t.negate:
+		t.kind = termExact
+	}
+
+	t.text = tok
+	return t, strings.TrimSpace(t.text) != ""
+}
+
+// narrows determines whether every command that matches q also matches prev, which is the case
+// when q only adds to prev's fuzzy text, tags and groups.
+func (q *query) narrows(prev *query) bool {
+	if q.ignoreDiacritics != prev.ignoreDiacritics || !strings.HasPrefix(q.cleaned, prev.cleaned) {
+		return false
+	}
+
+	// Commands match a query tag if they have a tag that it is a prefix of, so each of prev's tags
+	// must be a prefix of one of q's tags
+	for _, pt := range prev.tags {
+		found := false
+		for _, t := range q.tags {
+			if strings.HasPrefix(t, pt) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, pg := range prev.groups {
+		found := false
+		for _, g := range q.groups {
+			if groupImplies(g, pg) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// groupImplies determines whether every command that matches group g also matches group prev.
+func groupImplies(g, prev []queryTerm) bool {
+	for _, t := range g {
+		found := false
+		for _, pt := range prev {
+			if t.implies(pt) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// implies determines whether every command that matches t also matches prev. Besides identical
+// terms, this is the case when a positive fuzzy, exact or prefix term is extended (other than an
+// exact tag, which has to match the whole tag).
+func (t queryTerm) implies(prev queryTerm) bool {
+	if t == prev {
+		return true
+	} else if t.field != prev.field || t.kind != prev.kind || t.negate || prev.negate {
+		return false
+	} else if t.kind == termSuffix || (t.field == fieldTag && t.kind == termExact) {
+		return false
+	}
+	return strings.HasPrefix(t.text, prev.text)
+}
@@ -0,0 +1,204 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		msg   string
+		query string
+		want  query
+	}{
+		{
+			msg:   "plain terms",
+			query: "  Git   LOG ",
+			want:  query{cleaned: "git log"},
+		},
+		{
+			msg:   "tags",
+			query: "#k8s logs tag:prod",
+			want:  query{cleaned: "logs", tags: []string{"k8s", "prod"}},
+		},
+		{
+			msg:   "field scopes",
+			query: "inv:docker desc:cleanup src:team",
+			want: query{groups: [][]queryTerm{
+				{{field: fieldInvocation, text: "docker"}},
+				{{field: fieldDescription, text: "cleanup"}},
+				{{field: fieldSource, text: "team"}},
+			}},
+		},
+		{
+			msg:   "match kinds",
+			query: "'exact ^git $--force",
+			want: query{groups: [][]queryTerm{
+				{{kind: termExact, text: "exact"}},
+				{{kind: termPrefix, text: "git"}},
+				{{kind: termSuffix, text: "--force"}},
+			}},
+		},
+		{
+			msg:   "negated terms are exact unless another kind is given",
+			query: "!sudo !^rm !inv:$-f",
+			want: query{groups: [][]queryTerm{
+				{{kind: termExact, text: "sudo", negate: true}},
+				{{kind: termPrefix, text: "rm", negate: true}},
+				{{field: fieldInvocation, kind: termSuffix, text: "-f", negate: true}},
+			}},
+		},
+		{
+			msg:   "negated and modified tags",
+			query: "!#k8s #'logs",
+			want: query{groups: [][]queryTerm{
+				{{field: fieldTag, kind: termPrefix, text: "k8s", negate: true}},
+				{{field: fieldTag, kind: termExact, text: "logs"}},
+			}},
+		},
+		{
+			msg:   "exact phrases",
+			query: "'git log' desc:'the commit graph' x",
+			want: query{cleaned: "x", groups: [][]queryTerm{
+				{{kind: termExact, text: "git log"}},
+				{{field: fieldDescription, kind: termExact, text: "the commit graph"}},
+			}},
+		},
+		{
+			msg:   "unclosed phrases end at whitespace",
+			query: "'git log",
+			want: query{cleaned: "log", groups: [][]queryTerm{
+				{{kind: termExact, text: "git"}},
+			}},
+		},
+		{
+			msg:   "quotes within words do not close phrases",
+			query: "'it's here'",
+			want: query{groups: [][]queryTerm{
+				{{kind: termExact, text: "it's here"}},
+			}},
+		},
+		{
+			msg:   "alternatives",
+			query: "docker | podman build | #k8s",
+			want: query{groups: [][]queryTerm{
+				{{text: "docker"}, {text: "podman"}},
+				{{text: "build"}, {field: fieldTag, kind: termPrefix, text: "k8s"}},
+			}},
+		},
+		{
+			msg:   "dangling alternatives are ignored",
+			query: "| docker |",
+			want:  query{cleaned: "docker"},
+		},
+		{
+			msg:   "terms without text are ignored",
+			query: "git ! inv: ' ^ # |",
+			want:  query{cleaned: "git"},
+		},
+		{
+			msg:   "unknown fields are plain text",
+			query: "https://example.com",
+			want:  query{cleaned: "https://example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			got := parseQuery(tt.query)
+			tt.want.raw = tt.query
+			if diff := cmp.Diff(*got, tt.want, cmp.AllowUnexported(query{}, queryTerm{}), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Parsed query diff (-got, +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseModeQuery(t *testing.T) {
+	tests := []struct {
+		msg   string
+		query string
+		mode  SearchMode
+		want  query
+	}{
+		{
+			msg:   "fuzzy",
+			query: "git log",
+			mode:  FuzzyMode,
+			want:  query{cleaned: "git log"},
+		},
+		{
+			msg:   "exact terms",
+			query: "git inv:log | #k8s !sudo ^x",
+			mode:  ExactMode,
+			want: query{groups: [][]queryTerm{
+				{{field: fieldInvocation, kind: termExact, text: "log"}, {field: fieldTag, kind: termPrefix, text: "k8s"}},
+				{{kind: termExact, text: "sudo", negate: true}},
+				{{kind: termPrefix, text: "x"}},
+				{{kind: termExact, text: "git"}},
+			}},
+		},
+		{
+			msg:   "prefix of the whole query",
+			query: " Git log !x ",
+			mode:  PrefixMode,
+			want: query{groups: [][]queryTerm{
+				{{kind: termPrefix, text: "git log !x"}},
+			}},
+		},
+		{
+			msg:   "empty prefix",
+			query: " ",
+			mode:  PrefixMode,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			got := parseModeQuery(tt.query, tt.mode)
+			tt.want.raw = tt.query
+			if diff := cmp.Diff(*got, tt.want, cmp.AllowUnexported(query{}, queryTerm{}), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Parsed query diff (-got, +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNarrows(t *testing.T) {
+	tests := []struct {
+		prev, q string
+		want    bool
+	}{
+		{prev: "", q: "git", want: true},
+		{prev: "gi", q: "git", want: true},
+		{prev: "git", q: "gi", want: false},
+		{prev: "git", q: "got", want: false},
+		{prev: "#k", q: "#k8s", want: true},
+		{prev: "#k8s", q: "#k", want: false},
+		{prev: "log", q: "#k8s log", want: true},
+		{prev: "#k8s log", q: "log", want: false},
+		{prev: "inv:do", q: "inv:doc", want: true},
+		{prev: "inv:do", q: "desc:doc", want: false},
+		{prev: "'do", q: "'doc", want: true},
+		{prev: "^do", q: "^doc", want: true},
+		{prev: "$do", q: "$doc", want: false},
+		{prev: "#'k", q: "#'k8s", want: false},
+		{prev: "!sud", q: "!sudo", want: false},
+		{prev: "!sudo", q: "!sudo git", want: true},
+		{prev: "docker | podman", q: "docker | podman build", want: true},
+		{prev: "docker | podman", q: "docker | inv:podman", want: false},
+		{prev: "docker", q: "docker | podman", want: false},
+		{prev: "git", q: "git |", want: true},
+		{prev: "git", q: "git | docker", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := parseQuery(tt.q).narrows(parseQuery(tt.prev)); got != tt.want {
+			t.Errorf("Query %q narrows %q: %t, want %t", tt.q, tt.prev, got, tt.want)
+		}
+	}
+}
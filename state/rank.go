@@ -0,0 +1,87 @@
+package state
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Weights used when scoring the quality of a match, on top of the score from match. Matches which
+// start later are penalized by up to maxStartPenalty, and description matches are discounted.
+const (
+	maxStartPenalty       = 20
+	descriptionOnlyFactor = 0.8
+)
+
+// frecencyWeight scales the frecency of a command so that it is comparable to match scores.
+const frecencyWeight = 5
+
+// recencyBuckets assigns weights to uses based on how long ago they were. Uses older than the last
+// bucket get a weight of oldUseWeight.
+var recencyBuckets = []struct {
+	age    time.Duration
+	weight float64
+}{
+	{age: 4 * time.Hour, weight: 4},
+	{age: 24 * time.Hour, weight: 2},
+	{age: 7 * 24 * time.Hour, weight: 1},
+	{age: 30 * 24 * time.Hour, weight: 0.5},
+}
+
+const oldUseWeight = 0.25
+
+// rank sorts the given matches in-place from best to worst. The score of each match is a
+// combination of the quality of its highlighted chunks and the frecency (frequency and recency
+// of use) of the command. Ties are broken by the original order of the matches so that
+// ranking is deterministic.
+func rank(matches []matchedCommand, t time.Time) {
+	for i := range matches {
+		m := &matches[i]
+		m.score = matchQuality(m) + frecency(m.c, t)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+}
+
+// matchQuality scores the invocation and description matches of a command, returning the better
+// of the two. Description matches are weighted lower than invocation matches. Commands without
+// any highlighted text (for example, when the query is empty) have a quality of zero.
+func matchQuality(m *matchedCommand) float64 {
+	inv := adjustedScore(m.invScore, m.invMatches)
+	desc := adjustedScore(m.descScore, m.descMatches) * descriptionOnlyFactor
+	return math.Max(inv, desc)
+}
+
+// adjustedScore penalizes the score of a match by how far into the text it starts, as matches
+// closer to the start of a command are more likely to be what the user is looking for.
+func adjustedScore(score int, chunks []matchedText) float64 {
+	if len(chunks) == 0 {
+		return 0
+	}
+	start := chunks[0].start
+	if start > maxStartPenalty {
+		start = maxStartPenalty
+	}
+	return float64(score - start)
+}
+
+// frecency combines how often and how recently a command was used as of time t. The count is
+// scaled logarithmically so that heavily used commands do not drown out match quality.
+func frecency(c *Command, t time.Time) float64 {
+	if c.Usage == nil || c.Usage.Count <= 0 {
+		return 0
+	}
+
+	weight := oldUseWeight
+	age := t.Sub(c.Usage.Last)
+	for _, b := range recencyBuckets {
+		if age < b.age {
+			weight = b.weight
+			break
+		}
+	}
+
+	return frecencyWeight * weight * math.Log2(1+float64(c.Usage.Count))
+}
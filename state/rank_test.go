@@ -0,0 +1,144 @@
+package state
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRank(t *testing.T) {
+	current := time.Date(2022, time.August, 1, 12, 0, 0, 0, time.UTC)
+	used := func(count int, ago time.Duration) *Usage {
+		return &Usage{Count: count, Last: current.Add(-ago)}
+	}
+
+	tests := []struct {
+		msg      string
+		query    string
+		commands []*Command
+		want     []string
+	}{
+		{
+			msg:   "empty query preserves file order without usage",
+			query: "",
+			commands: []*Command{
+				{Invocation: "go build"},
+				{Invocation: "go test"},
+				{Invocation: "go vet"},
+			},
+			want: []string{"go build", "go test", "go vet"},
+		},
+		{
+			msg:   "empty query ranks by frecency",
+			query: "",
+			commands: []*Command{
+				{Invocation: "go build", Usage: used(1, 90*24*time.Hour)},
+				{Invocation: "go test"},
+				{Invocation: "go vet", Usage: used(1, time.Minute)},
+				{Invocation: "go run", Usage: used(20, 90*24*time.Hour)},
+			},
+			want: []string{"go vet", "go run", "go build", "go test"},
+		},
+		{
+			msg:   "fewer chunks rank higher",
+			query: "gob",
+			commands: []*Command{
+				{Invocation: "git log --oneline --graph --branches"},
+				{Invocation: "gob build"},
+			},
+			want: []string{"gob build", "git log --oneline --graph --branches"},
+		},
+		{
+			msg:   "earlier starts rank higher",
+			query: "pods",
+			commands: []*Command{
+				{Invocation: "kubectl --namespace kube-system get pods"},
+				{Invocation: "kubectl get pods"},
+			},
+			want: []string{"kubectl get pods", "kubectl --namespace kube-system get pods"},
+		},
+		{
+			msg:   "word boundaries rank higher",
+			query: "log",
+			commands: []*Command{
+				{Invocation: "xlogs"},
+				{Invocation: "x log"},
+			},
+			want: []string{"x log", "xlogs"},
+		},
+		{
+			msg:   "invocation matches rank higher than equivalent description matches",
+			query: "deploy",
+			commands: []*Command{
+				{Invocation: "make release", Description: "deploy"},
+				{Invocation: "deploy", Description: "release"},
+			},
+			want: []string{"deploy", "make release"},
+		},
+		{
+			msg:   "recent use outweighs a slightly worse match",
+			query: "pods",
+			commands: []*Command{
+				{Invocation: "kubectl get pods"},
+				{Invocation: "kubectl --namespace kube-system get pods", Usage: used(5, time.Hour)},
+			},
+			want: []string{"kubectl --namespace kube-system get pods", "kubectl get pods"},
+		},
+		{
+			msg:   "old use does not outweigh a much better match",
+			query: "build",
+			commands: []*Command{
+				{Invocation: "bu i ld", Usage: used(2, 60*24*time.Hour)},
+				{Invocation: "go build"},
+			},
+			want: []string{"go build", "bu i ld"},
+		},
+		{
+			msg:   "ties preserve file order",
+			query: "run",
+			commands: []*Command{
+				{Invocation: "go run a", Usage: used(3, time.Hour)},
+				{Invocation: "go run b", Usage: used(3, time.Hour)},
+				{Invocation: "go run c", Usage: used(3, time.Hour)},
+			},
+			want: []string{"go run a", "go run b", "go run c"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			matches, err := matchAll(context.Background(), tt.commands, fuzzyMatcher(parseQuery(tt.query)))
+			if err != nil {
+				t.Fatalf("Unexpected search error: %v", err)
+			}
+
+			rank(matches, current)
+
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.c.Invocation)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("Ranked invocations diff (-got, +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRecordUse(t *testing.T) {
+	current := time.Date(2022, time.August, 1, 12, 0, 0, 500, time.UTC)
+	defer func(orig func() time.Time) { now = orig }(now)
+	now = func() time.Time { return current }
+
+	c := &Command{Invocation: "go build"}
+	c.RecordUse()
+	c.RecordUse()
+
+	want := &Usage{Count: 2, Last: current.Truncate(time.Second)}
+	if diff := cmp.Diff(c.Usage, want); diff != "" {
+		t.Errorf("Usage diff (-got, +want):\n%s", diff)
+	}
+}
@@ -1,28 +1,85 @@
 package state
 
 import (
+	"context"
+	"fmt"
 	"regexp"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/rithvikp/speeddial/term"
 )
 
-// Searcher provides a searchable view over all commands. It conforms to the
-// term.QueryableList interface.
+// SearchMode determines how a Searcher interprets queries.
+type SearchMode int
+
+// Define the search modes, in the order in which they are cycled through.
+const (
+	// FuzzyMode matches queries as fuzzy text, with support for the extended syntax (see query).
+	FuzzyMode SearchMode = iota
+	// ExactMode is like FuzzyMode, but terms that would be fuzzy are matched as substrings.
+	ExactMode
+	// RegexMode treats the query as a regular expression.
+	RegexMode
+	// PrefixMode matches commands whose invocation or description starts with the query.
+	PrefixMode
+	numSearchModes
+)
+
+func (m SearchMode) String() string {
+	switch m {
+	case FuzzyMode:
+		return "fuzzy"
+	case ExactMode:
+		return "exact"
+	case RegexMode:
+		return "regex"
+	case PrefixMode:
+		return "prefix"
+	}
+	return "unknown"
+}
+
+// SearchOptions configures how a Searcher matches commands.
+type SearchOptions struct {
+	// Mode is the initial search mode, which can be changed while searching.
+	Mode SearchMode
+	// IgnoreDiacritics makes fuzzy search match accented characters to their base characters,
+	// e.g. "cafe" matches "café".
+	IgnoreDiacritics bool
+	// CaseInsensitive makes regex search ignore case. Fuzzy search always ignores case.
+	CaseInsensitive bool
+}
+
+// Searcher provides a searchable view over all commands. It conforms to the term.QueryableList,
+// term.CancellableList, term.ToggleableList and term.ModalList interfaces, and is safe for
+// concurrent use as long as the container is not modified during a search.
 type Searcher struct {
-	c     *Container
-	regex bool
+	c *Container
+
+	mu   sync.Mutex
+	opts SearchOptions
+	// The last completed fuzzy search, whose results are reused if the next query extends it.
+	last *lastSearch
 }
 
-// Searcher returns a Searcher over the container.
-func (c *Container) Searcher(useRegex bool) *Searcher {
-	return &Searcher{c: c, regex: useRegex}
+type lastSearch struct {
+	q *query
+	// The commands that matched the query, in their original order.
+	commands []*Command
+	// The generation of the container at the time of the search.
+	generation int
 }
 
-type query struct {
-	raw      string
-	cleaned  string
-	unigrams []string
+// searchChunkSize is the number of commands matched by each goroutine during a search.
+const searchChunkSize = 512
+
+// Searcher returns a Searcher over the container.
+func (c *Container) Searcher(opts SearchOptions) *Searcher {
+	return &Searcher{c: c, opts: opts}
 }
 
 type matchedText struct {
@@ -36,214 +93,436 @@ type matchedCommand struct {
 	invMatches []matchedText
 	// Matches on the description string
 	descMatches []matchedText
+	// Matches on the tag string
+	tagMatches []matchedText
+	// The scores of the invocation and description matches (see match)
+	invScore  int
+	descScore int
+	// The ranking score, with higher being better
+	score float64
 }
 
-type searchMethod func(q *query, s *state) ([]matchedCommand, error)
+// commandMatcher determines whether a single command matches a query.
+type commandMatcher func(c *Command) (matchedCommand, bool)
 
-// Search searches all state in this container based on the given query.
+// Search searches all state in this container based on the given query. Results are ranked by
+// a combination of match quality and how frequently and recently each command was used.
 func (s *Searcher) Search(rawQuery string) ([]term.ListItem[*Command], error) {
-	var matched []term.ListItem[*Command]
-	q := parseQuery(rawQuery)
+	return s.SearchContext(context.Background(), rawQuery)
+}
+
+// SearchContext is like Search, but it stops early and returns the context's error if the context
+// is cancelled. Commands are matched concurrently and, if the query extends the previous one (for
+// example, when the user types another character), only the previous results are searched.
+func (s *Searcher) SearchContext(ctx context.Context, rawQuery string) ([]term.ListItem[*Command], error) {
+	opts := s.options()
+	q := parseModeQuery(rawQuery, opts.Mode)
+	q.ignoreDiacritics = opts.IgnoreDiacritics
+
+	m, err := matcher(q, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := matchAll(ctx, s.candidates(q), m)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Mode != RegexMode {
+		s.remember(q, matches)
+	}
+
+	rank(matches, now())
 
-	for _, st := range s.c.states {
-		matches, err := s.dispatch()(q, st)
-		if err != nil {
-			return nil, err
+	matched := make([]term.ListItem[*Command], 0, len(matches))
+	for _, m := range matches {
+		inv := term.FormattedContent{
+			Content: m.c.Invocation,
 		}
 
-		for _, m := range matches {
-			inv := term.FormattedContent{
-				Content: m.c.Invocation,
-			}
+		for _, mt := range m.invMatches {
+			inv.Highlights = append(inv.Highlights, term.FormattedChunk{
+				Start:  mt.start,
+				Length: mt.length,
+			})
+		}
 
-			for _, mt := range m.invMatches {
-				inv.Highlights = append(inv.Highlights, term.FormattedChunk{
-					Start:  mt.start,
-					Length: mt.length,
-				})
-			}
+		desc := term.FormattedContent{
+			Content: m.c.Description,
+		}
 
-			desc := term.FormattedContent{
-				Content: m.c.Description,
-			}
+		for _, mt := range m.descMatches {
+			desc.Highlights = append(desc.Highlights, term.FormattedChunk{
+				Start:  mt.start,
+				Length: mt.length,
+			})
+		}
 
-			for _, mt := range m.descMatches {
-				desc.Highlights = append(desc.Highlights, term.FormattedChunk{
-					Start:  mt.start,
-					Length: mt.length,
-				})
-			}
+		tags := term.FormattedContent{
+			Content: m.c.tagString(),
+		}
 
-			li := term.ListItem[*Command]{
-				DisplayFields: []term.FormattedContent{inv, desc},
-				Raw:           m.c,
-			}
+		for _, mt := range m.tagMatches {
+			tags.Highlights = append(tags.Highlights, term.FormattedChunk{
+				Start:  mt.start,
+				Length: mt.length,
+			})
+		}
 
-			matched = append(matched, li)
+		fields := []term.FormattedContent{inv, desc, tags}
+		// The source is only relevant if commands can come from multiple sources
+		if len(s.c.states) > 1 {
+			fields = append(fields, term.FormattedContent{Content: m.c.Source()})
 		}
+
+		li := term.ListItem[*Command]{
+			DisplayFields: fields,
+			Raw:           m.c,
+		}
+
+		matched = append(matched, li)
 	}
 
 	return matched, nil
 }
 
-func (s *Searcher) dispatch() searchMethod {
-	if s.regex {
-		return regexSearch
+// options returns a copy of the searcher's options, which can be changed by its toggles.
+func (s *Searcher) options() SearchOptions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opts
+}
+
+// Mode returns the name of the current search mode.
+func (s *Searcher) Mode() string {
+	return s.options().Mode.String()
+}
+
+// NextMode switches to the next search mode, wrapping around after the last one.
+func (s *Searcher) NextMode() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.Mode = (s.opts.Mode + 1) % numSearchModes
+}
+
+// Toggles returns the options that can be toggled while searching. Currently, case sensitivity
+// can be toggled with alt-c in regex mode.
+func (s *Searcher) Toggles() []term.Toggle {
+	if s.options().Mode != RegexMode {
+		return nil
 	}
-	return search
+
+	return []term.Toggle{{
+		Key:   'c',
+		Label: "ignore case",
+		Enabled: func() bool {
+			return s.options().CaseInsensitive
+		},
+		Flip: func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.opts.CaseInsensitive = !s.opts.CaseInsensitive
+		},
+	}}
 }
 
-func regexSearch(q *query, s *state) ([]matchedCommand, error) {
-	expr, err := regexp.Compile(q.raw)
+func matcher(q *query, opts SearchOptions) (commandMatcher, error) {
+	if opts.Mode != RegexMode {
+		return fuzzyMatcher(q), nil
+	}
+
+	raw := q.raw
+	if opts.CaseInsensitive {
+		raw = "(?i)" + raw
+	}
+	expr, err := regexp.Compile(raw)
 	if err != nil {
-		return nil, term.ErrQueryableListInvalidQuery
+		return nil, fmt.Errorf("%w: %v", term.ErrQueryableListInvalidQuery, err)
 	}
+	return func(c *Command) (matchedCommand, bool) {
+		return regexMatch(expr, c)
+	}, nil
+}
 
-	var matched []matchedCommand
-	for _, c := range s.Commands {
-		c.state = s
-		match := expr.FindStringIndex(c.Invocation)
-		if match == nil {
-			continue
-		}
+// candidates returns the commands which could match the query. If the query narrows the last
+// search, only the commands which matched it need to be considered.
+func (s *Searcher) candidates(q *query) []*Command {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		matched = append(matched, matchedCommand{
-			c:          c,
-			invMatches: []matchedText{{start: match[0], length: match[1] - match[0]}},
-		})
+	if s.last != nil && s.last.generation == s.c.generation && q.narrows(s.last.q) {
+		return s.last.commands
 	}
-
-	return matched, nil
+	return s.c.List()
 }
 
-func parseQuery(raw string) *query {
-	q := query{
-		raw:     raw,
-		cleaned: strings.TrimSpace(strings.ToLower(raw)),
+// remember records the results of a completed fuzzy search so that later searches can narrow
+// them.
+func (s *Searcher) remember(q *query, matches []matchedCommand) {
+	commands := make([]*Command, 0, len(matches))
+	for _, m := range matches {
+		commands = append(commands, m.c)
 	}
-	q.unigrams = strings.Fields(q.cleaned)
-	return &q
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = &lastSearch{q: q, commands: commands, generation: s.c.generation}
 }
 
-// search searches the commands in the given state to find any that match to the query. Currently,
-// matching is purely based on "contains" operations.
-func search(q *query, s *state) ([]matchedCommand, error) {
-	var matched []matchedCommand
+// matchAll matches every command, returning the matches in the same order as the commands. Large
+// sets of commands are split into chunks that are matched concurrently.
+func matchAll(ctx context.Context, commands []*Command, m commandMatcher) ([]matchedCommand, error) {
+	numChunks := (len(commands) + searchChunkSize - 1) / searchChunkSize
+	results := make([][]matchedCommand, numChunks)
 
-	for _, c := range s.Commands {
-		c.state = s
-		mc := matchedCommand{
-			c: c,
+	matchChunk := func(i int) {
+		end := (i + 1) * searchChunkSize
+		if end > len(commands) {
+			end = len(commands)
 		}
 
-		if q.cleaned == "" {
-			matched = append(matched, mc)
-			continue
+		for j, c := range commands[i*searchChunkSize : end] {
+			// Checking for cancellation involves a lock, so it is done periodically
+			if j%64 == 0 && ctx.Err() != nil {
+				return
+			}
+			if mc, ok := m(c); ok {
+				results[i] = append(results[i], mc)
+			}
 		}
+	}
 
-		mc.invMatches = match(q, c.Invocation)
-		mc.descMatches = match(q, c.Description)
-		if len(mc.invMatches) > 0 || len(mc.descMatches) > 0 {
-			matched = append(matched, mc)
+	if numChunks == 1 {
+		matchChunk(0)
+	} else {
+		var wg sync.WaitGroup
+		workers := make(chan struct{}, runtime.GOMAXPROCS(0))
+		for i := range results {
+			wg.Add(1)
+			workers <- struct{}{}
+			go func(i int) {
+				defer wg.Done()
+				matchChunk(i)
+				<-workers
+			}(i)
 		}
+		wg.Wait()
 	}
 
-	return matched, nil
-}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-// match takes a given query and determines whether it exists in src as a sequence of
-// (potentially non-consecutive) substrings, returning the substrings if a match is found.
-//
-// A DP algorithm is used: it looks at successive prefixes of src and query, keeping track of
-// all matches so far. At the end, the full match (ie. a match where the entire query appears
-// in some form in src) with the fewest chunks (and then the earliest chunk as a tiebreaker)
-// is returned.
-//
-// TODO: Minimize the number of tokens that are split across chunks.
-func match(q *query, src string) []matchedText {
-	if q.cleaned == "" || src == "" || len(q.cleaned) > len(src) {
-		return nil
+	var matches []matchedCommand
+	for _, r := range results {
+		matches = append(matches, r...)
 	}
+	return matches, nil
+}
 
-	type state struct {
-		valid   bool
-		matches [][]matchedText
+// regexMatch determines whether the expression matches the command's invocation, description or
+// tags, highlighting every match. Each field is scored by the amount of text that it matched.
+func regexMatch(expr *regexp.Regexp, c *Command) (matchedCommand, bool) {
+	mc := matchedCommand{
+		c: c,
 	}
 
-	src = strings.TrimSpace(strings.ToLower(src))
+	var invFound, descFound, tagFound bool
+	mc.invMatches, mc.invScore, invFound = regexMatchText(expr, c.Invocation)
+	mc.descMatches, mc.descScore, descFound = regexMatchText(expr, c.Description)
+	mc.tagMatches, _, tagFound = regexMatchText(expr, c.tagString())
+	return mc, invFound || descFound || tagFound
+}
 
-	min := func(a, b int) int {
-		if a <= b {
-			return a
+// regexMatchText finds every match of the expression in src. Empty matches (e.g. for "^") are not
+// highlighted but still count as the expression matching.
+func regexMatchText(expr *regexp.Regexp, src string) ([]matchedText, int, bool) {
+	indices := expr.FindAllStringIndex(src, -1)
+	var matches []matchedText
+	score := 0
+	for _, idx := range indices {
+		if idx[1] > idx[0] {
+			matches = append(matches, matchedText{start: idx[0], length: idx[1] - idx[0]})
+			score += scoreMatch * (idx[1] - idx[0])
 		}
-		return b
 	}
+	return matches, score, len(indices) > 0
+}
 
-	dp := make([][]state, len(src))
+// matchTags determines whether every tag in the query is a prefix of one of the command's tags,
+// returning the matching sections of the command's tag string.
+func matchTags(q *query, c *Command) ([]matchedText, bool) {
+	var matches []matchedText
+	for _, qt := range q.tags {
+		m, ok := matchTag(termPrefix, qt, c)
+		if !ok {
+			return nil, false
+		}
+		matches = append(matches, m)
+	}
 
-	for i := 0; i < len(src); i++ {
-		dp[i] = make([]state, len(q.cleaned))
-		if src[i] == q.cleaned[0] {
-			dp[i][0].valid = true
-			dp[i][0].matches = append(dp[i][0].matches, []matchedText{{
-				start:  i,
-				length: 1,
-			}})
-		} else if i > 0 && dp[i-1][0].valid {
-			// If an element of the first column is valid, it will have exactly one match list
-			dp[i][0].valid = true
-			dp[i][0].matches = append(dp[i][0].matches, []matchedText{})
-			dp[i][0].matches[0] = append(dp[i][0].matches[0], dp[i-1][0].matches[0]...)
+	// Multiple query tags can match the same command tag, so overlapping chunks are merged.
+	return mergeMatches(matches), true
+}
+
+// matchTag finds the first of the command's tags that matches the text, returning its section of
+// the command's tag string. Prefix matches highlight the matched prefix and suffix matches the
+// matched suffix, while exact matches must match the whole tag.
+func matchTag(kind termKind, text string, c *Command) (matchedText, bool) {
+	offset := 0
+	for _, t := range c.Tags {
+		// Skip over the leading '#'
+		offset++
+		switch {
+		case kind == termExact && t == text:
+			return matchedText{start: offset, length: len(t)}, true
+		case kind == termPrefix && strings.HasPrefix(t, text):
+			return matchedText{start: offset, length: len(text)}, true
+		case kind == termSuffix && strings.HasSuffix(t, text):
+			return matchedText{start: offset + len(t) - len(text), length: len(text)}, true
 		}
+		// Skip over the tag and the trailing space
+		offset += len(t) + 1
+	}
+	return matchedText{}, false
+}
 
-		for j := 1; j < min(i+1, len(q.cleaned)); j++ {
-			if src[i] == q.cleaned[j] && dp[i-1][j-1].valid {
-				for k := 0; k < len(dp[i-1][j-1].matches); k++ {
-					dp[i][j].valid = true
-					dp[i][j].matches = append(dp[i][j].matches, []matchedText{})
-					dp[i][j].matches[k] = append(dp[i][j].matches[k], dp[i-1][j-1].matches[k]...)
+// fuzzyMatcher returns a commandMatcher for fuzzy searches with the given query.
+func fuzzyMatcher(q *query) commandMatcher {
+	return func(c *Command) (matchedCommand, bool) {
+		return fuzzyMatch(q, c)
+	}
+}
 
-					prev := &dp[i][j].matches[k][len(dp[i][j].matches[k])-1]
-					if prev.start+prev.length == i {
-						prev.length++
-					} else {
-						dp[i][j].matches[k] = append(dp[i][j].matches[k], matchedText{
-							start:  i,
-							length: 1,
-						})
-					}
-				}
-			}
+// fuzzyMatch determines whether the command matches the query: it must fuzzy match the query's
+// cleaned text in its invocation or description, have every tag in the query and match each
+// group of terms. The highlights of every matched term are combined.
+func fuzzyMatch(q *query, c *Command) (matchedCommand, bool) {
+	mc := matchedCommand{
+		c: c,
+	}
+
+	var ok bool
+	if mc.tagMatches, ok = matchTags(q, c); !ok {
+		return mc, false
+	}
 
-			if dp[i-1][j].valid {
-				offset := len(dp[i][j].matches)
-				for k := 0; k < len(dp[i-1][j].matches); k++ {
-					dp[i][j].valid = true
-					dp[i][j].matches = append(dp[i][j].matches, []matchedText{})
-					dp[i][j].matches[offset+k] = append(dp[i][j].matches[offset+k], dp[i-1][j].matches[k]...)
-				}
+	// Groups are checked before the cleaned text as most terms are cheaper to match
+	for _, g := range q.groups {
+		found := false
+		for _, t := range g {
+			tm, ok := matchTerm(t, c, q.ignoreDiacritics)
+			if ok == t.negate {
+				continue
 			}
+			found = true
+			if !t.negate {
+				mc.combine(tm)
+			}
+		}
+		if !found {
+			return mc, false
 		}
 	}
 
-	var bestMatch []matchedText
-	matches := dp[len(src)-1][len(q.cleaned)-1].matches
-	for _, m := range matches {
-		if len(bestMatch) == 0 || len(m) < len(bestMatch) {
-			bestMatch = m
-		} else if len(m) == len(bestMatch) {
-			// As a precondition, all match lists should be non-empty, and chunks should be ordered
-			// in increasing order of index.
-			i := 0
-			for i < len(m) && m[i].start == bestMatch[i].start {
-				i++
-			}
+	if q.cleaned != "" {
+		var tm matchedCommand
+		tm.invMatches, tm.invScore = match(q, c.Invocation)
+		tm.descMatches, tm.descScore = match(q, c.Description)
+		if len(tm.invMatches) == 0 && len(tm.descMatches) == 0 {
+			return mc, false
+		}
+		mc.combine(tm)
+	}
 
-			if i < len(m) && m[i].start < bestMatch[i].start {
-				bestMatch = m
+	return mc, true
+}
+
+// matchTerm determines whether a single query term matches the command, ignoring whether the term
+// is negated. The returned matchedCommand only contains the term's highlights and scores. Matches
+// on the source are not highlighted.
+func matchTerm(t queryTerm, c *Command, ignoreDiacritics bool) (matchedCommand, bool) {
+	var tm matchedCommand
+	matchField := func(src string) ([]matchedText, int) {
+		if t.kind == termFuzzy {
+			return matchFuzzy(t.text, src, ignoreDiacritics)
+		}
+		return matchLiteral(t.kind, t.text, src, ignoreDiacritics)
+	}
+
+	switch t.field {
+	case fieldTag:
+		m, ok := matchTag(t.kind, t.text, c)
+		if ok {
+			tm.tagMatches = []matchedText{m}
+		}
+		return tm, ok
+	case fieldSource:
+		m, _ := matchField(c.Source())
+		return tm, len(m) > 0
+	}
+
+	if t.field != fieldDescription {
+		tm.invMatches, tm.invScore = matchField(c.Invocation)
+	}
+	if t.field != fieldInvocation {
+		tm.descMatches, tm.descScore = matchField(c.Description)
+	}
+	return tm, len(tm.invMatches) > 0 || len(tm.descMatches) > 0
+}
+
+// combine adds the highlights and scores of another match of the same command.
+func (mc *matchedCommand) combine(other matchedCommand) {
+	mc.invMatches = mergeMatches(append(mc.invMatches, other.invMatches...))
+	mc.descMatches = mergeMatches(append(mc.descMatches, other.descMatches...))
+	mc.tagMatches = mergeMatches(append(mc.tagMatches, other.tagMatches...))
+	mc.invScore += other.invScore
+	mc.descScore += other.descScore
+}
+
+// mergeMatches sorts matches and merges any that overlap or touch, as the highlights of different
+// query terms may cover the same text.
+func mergeMatches(matches []matchedText) []matchedText {
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].start < matches[j].start
+	})
+
+	var merged []matchedText
+	for _, m := range matches {
+		if n := len(merged); n > 0 && m.start <= merged[n-1].start+merged[n-1].length {
+			if end := m.start + m.length; end > merged[n-1].start+merged[n-1].length {
+				merged[n-1].length = end - merged[n-1].start
 			}
+			continue
 		}
+		merged = append(merged, m)
 	}
+	return merged
+}
 
-	return bestMatch
+// trimFolded removes leading and trailing whitespace from folded text.
+func trimFolded(text []foldedRune) []foldedRune {
+	for len(text) > 0 && unicode.IsSpace(text[0].r) {
+		text = text[1:]
+	}
+	for len(text) > 0 && unicode.IsSpace(text[len(text)-1].r) {
+		text = text[:len(text)-1]
+	}
+	return text
+}
+
+// byteOffsets converts matches given as indices into folded text into byte offsets into the
+// original text.
+func byteOffsets(text []foldedRune, matches []matchedText) []matchedText {
+	if matches == nil {
+		return nil
+	}
+
+	converted := make([]matchedText, 0, len(matches))
+	for _, m := range matches {
+		start := text[m.start].start
+		end := text[m.start+m.length-1].end
+		converted = append(converted, matchedText{start: start, length: end - start})
+	}
+	return converted
 }
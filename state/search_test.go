@@ -1,17 +1,27 @@
 package state
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/rithvikp/speeddial/term"
+	"golang.org/x/exp/slices"
 )
 
 func TestMatch(t *testing.T) {
 	tests := []struct {
-		msg     string
-		query   string
-		src     string
-		matches []matchedText
+		msg              string
+		query            string
+		src              string
+		ignoreDiacritics bool
+		matches          []matchedText
 	}{
 		{
 			msg:   "no matches",
@@ -85,12 +95,102 @@ func TestMatch(t *testing.T) {
 			},
 		},
 		{
-			msg:   "minimize chunk count",
+			msg:   "consecutive runs beat scattered word starts",
+			query: "build",
+			src:   "bu i ld build",
+			matches: []matchedText{
+				{start: 8, length: 5},
+			},
+		},
+		{
+			msg:   "chunks at the start of words beat fewer chunks within words",
 			query: "build",
 			src:   "bu i ld ild",
 			matches: []matchedText{
 				{start: 0, length: 2},
-				{start: 8, length: 3},
+				{start: 3, length: 1},
+				{start: 5, length: 2},
+			},
+		},
+		{
+			msg:   "camelCase boundaries",
+			query: "fb",
+			src:   "foobar fooBar",
+			matches: []matchedText{
+				{start: 7, length: 1},
+				{start: 10, length: 1},
+			},
+		},
+		{
+			msg:   "path separators",
+			query: "main",
+			src:   "vim src/domain/main.go",
+			matches: []matchedText{
+				{start: 15, length: 4},
+			},
+		},
+		{
+			msg:   "offsets are in bytes after multi-byte characters",
+			query: "log",
+			src:   "café log",
+			matches: []matchedText{
+				{start: 6, length: 3},
+			},
+		},
+		{
+			msg:   "multi-byte characters in the query",
+			query: "日本語",
+			src:   "日本と日本語の説明",
+			matches: []matchedText{
+				{start: 9, length: 9},
+			},
+		},
+		{
+			msg:   "emoji",
+			query: "🚀 dep",
+			src:   "echo 🚀 && ./deploy.sh",
+			matches: []matchedText{
+				{start: 5, length: 5},
+				{start: 15, length: 3},
+			},
+		},
+		{
+			msg:   "case folding beyond ASCII",
+			query: "σίσυφος",
+			src:   "ΣΊΣΥΦΟΣ",
+			matches: []matchedText{
+				{start: 0, length: 14},
+			},
+		},
+		{
+			msg:   "case folding where the lowercase form has a different length",
+			query: "kelvin",
+			src:   "\u212Aelvin",
+			matches: []matchedText{
+				{start: 0, length: 8},
+			},
+		},
+		{
+			msg:   "diacritics are significant by default",
+			query: "cafe",
+			src:   "café",
+		},
+		{
+			msg:              "diacritics can be ignored",
+			query:            "creme brulee",
+			src:              "Crème brûlée",
+			ignoreDiacritics: true,
+			matches: []matchedText{
+				{start: 0, length: 15},
+			},
+		},
+		{
+			msg:              "combining marks are included in the highlighted text",
+			query:            "cafe",
+			src:              "cafe\u0301 au lait",
+			ignoreDiacritics: true,
+			matches: []matchedText{
+				{start: 0, length: 6},
 			},
 		},
 		// Will be re-added once this functionality is implemented.
@@ -109,11 +209,478 @@ func TestMatch(t *testing.T) {
 		tt := tt
 		t.Run(tt.msg, func(t *testing.T) {
 			q := parseQuery(tt.query)
+			q.ignoreDiacritics = tt.ignoreDiacritics
 
-			matches := match(q, tt.src)
+			matches, _ := match(q, tt.src)
 			if diff := cmp.Diff(matches, tt.matches, cmp.AllowUnexported(matchedText{})); diff != "" {
 				t.Errorf("[]matchedText diff (-got, +want):\n%s", diff)
 			}
 		})
 	}
 }
+
+func TestTagSearch(t *testing.T) {
+	commands := []*Command{
+		{Invocation: "kubectl logs -f api", Description: "Follow the api logs", Tags: []string{"k8s", "logs"}},
+		{Invocation: "kubectl get pods", Description: "List pods", Tags: []string{"k8s"}},
+		{Invocation: "tail -f /var/log/syslog", Description: "Follow the system logs", Tags: []string{"logs"}},
+		{Invocation: "git log --graph", Description: "Show the commit graph"},
+	}
+	c := &Container{states: []*state{{Commands: commands}}}
+
+	tests := []struct {
+		msg         string
+		query       string
+		invocations []string
+		tagMatches  [][]matchedText
+	}{
+		{
+			msg:         "no tags",
+			query:       "log",
+			invocations: []string{"kubectl logs -f api", "tail -f /var/log/syslog", "git log --graph"},
+			tagMatches:  [][]matchedText{nil, nil, nil},
+		},
+		{
+			msg:         "hash tag",
+			query:       "#k8s",
+			invocations: []string{"kubectl logs -f api", "kubectl get pods"},
+			tagMatches:  [][]matchedText{{{start: 1, length: 3}}, {{start: 1, length: 3}}},
+		},
+		{
+			msg:         "tag prefix combined with a fuzzy query",
+			query:       "tag:lo follow",
+			invocations: []string{"kubectl logs -f api", "tail -f /var/log/syslog"},
+			tagMatches:  [][]matchedText{{{start: 6, length: 2}}, {{start: 1, length: 2}}},
+		},
+		{
+			msg:         "multiple tags",
+			query:       "#LOGS #k8s",
+			invocations: []string{"kubectl logs -f api"},
+			tagMatches:  [][]matchedText{{{start: 1, length: 3}, {start: 6, length: 4}}},
+		},
+		{
+			msg:   "unknown tag",
+			query: "#docker",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			matches, err := matchAll(context.Background(), c.List(), fuzzyMatcher(parseQuery(tt.query)))
+			if err != nil {
+				t.Fatalf("Unexpected search error: %v", err)
+			}
+
+			var invocations []string
+			var tagMatches [][]matchedText
+			for _, m := range matches {
+				invocations = append(invocations, m.c.Invocation)
+				tagMatches = append(tagMatches, m.tagMatches)
+			}
+
+			if diff := cmp.Diff(invocations, tt.invocations); diff != "" {
+				t.Errorf("Matched invocations diff (-got, +want):\n%s", diff)
+			}
+			if diff := cmp.Diff(tagMatches, tt.tagMatches, cmp.AllowUnexported(matchedText{})); diff != "" {
+				t.Errorf("Tag matches diff (-got, +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExtendedSearch(t *testing.T) {
+	commands := []*Command{
+		{Invocation: "docker system prune -af", Description: "Cleanup unused images"},
+		{Invocation: "sudo docker ps", Description: "List containers"},
+		{Invocation: "git push --force", Description: "Overwrite the remote branch"},
+		{Invocation: "git log --graph", Description: "Show the commit graph", Tags: []string{"git", "log"}},
+	}
+	team := &state{name: "team", Commands: []*Command{{Invocation: "make deploy", Description: "Deploy the docker image"}}}
+	for _, c := range commands {
+		c.state = &state{name: "personal"}
+	}
+	commands = append(commands, team.Commands[0])
+	team.Commands[0].state = team
+
+	type result struct {
+		Invocation  string
+		InvMatches  []matchedText
+		DescMatches []matchedText
+		TagMatches  []matchedText
+	}
+	tests := []struct {
+		msg   string
+		query string
+		want  []result
+	}{
+		{
+			msg:   "field scopes",
+			query: "inv:docker desc:cleanup",
+			want: []result{
+				{Invocation: "docker system prune -af", InvMatches: []matchedText{{start: 0, length: 6}}, DescMatches: []matchedText{{start: 0, length: 7}}},
+			},
+		},
+		{
+			msg:   "negation",
+			query: "docker !sudo",
+			want: []result{
+				{Invocation: "docker system prune -af", InvMatches: []matchedText{{start: 0, length: 6}}},
+				{Invocation: "make deploy", DescMatches: []matchedText{{start: 11, length: 6}}},
+			},
+		},
+		{
+			msg:   "prefix and suffix",
+			query: "^git $--force",
+			want: []result{
+				{Invocation: "git push --force", InvMatches: []matchedText{{start: 0, length: 3}, {start: 9, length: 7}}},
+			},
+		},
+		{
+			msg:   "exact phrase",
+			query: "'commit graph'",
+			want: []result{
+				{Invocation: "git log --graph", DescMatches: []matchedText{{start: 9, length: 12}}},
+			},
+		},
+		{
+			msg:   "overlapping highlights are merged",
+			query: "'git log' inv:'it l",
+			want: []result{
+				{Invocation: "git log --graph", InvMatches: []matchedText{{start: 0, length: 7}}},
+			},
+		},
+		{
+			msg:   "alternatives",
+			query: "inv:^sudo | desc:remote",
+			want: []result{
+				{Invocation: "sudo docker ps", InvMatches: []matchedText{{start: 0, length: 4}}},
+				{Invocation: "git push --force", DescMatches: []matchedText{{start: 14, length: 6}}},
+			},
+		},
+		{
+			msg:   "source",
+			query: "src:team",
+			want: []result{
+				{Invocation: "make deploy"},
+			},
+		},
+		{
+			msg:   "negated source",
+			query: "docker !src:team",
+			want: []result{
+				{Invocation: "docker system prune -af", InvMatches: []matchedText{{start: 0, length: 6}}},
+				{Invocation: "sudo docker ps", InvMatches: []matchedText{{start: 5, length: 6}}},
+			},
+		},
+		{
+			msg:   "tag terms",
+			query: "#'log | !#git",
+			want: []result{
+				{Invocation: "docker system prune -af"},
+				{Invocation: "sudo docker ps"},
+				{Invocation: "git push --force"},
+				{Invocation: "git log --graph", TagMatches: []matchedText{{start: 6, length: 3}}},
+				{Invocation: "make deploy"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			matches, err := matchAll(context.Background(), commands, fuzzyMatcher(parseQuery(tt.query)))
+			if err != nil {
+				t.Fatalf("Unexpected search error: %v", err)
+			}
+
+			var got []result
+			for _, m := range matches {
+				got = append(got, result{Invocation: m.c.Invocation, InvMatches: m.invMatches, DescMatches: m.descMatches, TagMatches: m.tagMatches})
+			}
+			if diff := cmp.Diff(got, tt.want, cmp.AllowUnexported(matchedText{}), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Matches diff (-got, +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegexSearch(t *testing.T) {
+	commands := []*Command{
+		{Invocation: "git log --graph", Description: "Show the commit Graph", Tags: []string{"git"}},
+		{Invocation: "kubectl get pods", Description: "List pods", Tags: []string{"k8s", "pods"}},
+	}
+	c := &Container{states: []*state{{Commands: commands}}}
+	s := c.Searcher(SearchOptions{Mode: RegexMode})
+
+	type result struct {
+		Invocation string
+		Highlights [][]term.FormattedChunk
+	}
+	search := func(query string) []result {
+		t.Helper()
+		items, err := s.Search(query)
+		if err != nil {
+			t.Fatalf("Unexpected search error for %q: %v", query, err)
+		}
+		var got []result
+		for _, item := range items {
+			r := result{Invocation: item.Raw.Invocation}
+			for _, f := range item.DisplayFields {
+				r.Highlights = append(r.Highlights, f.Highlights)
+			}
+			got = append(got, r)
+		}
+		return got
+	}
+
+	tests := []struct {
+		msg             string
+		query           string
+		caseInsensitive bool
+		want            []result
+	}{
+		{
+			msg:   "every match in every field is highlighted",
+			query: "o[gmw]",
+			want: []result{
+				{Invocation: "git log --graph", Highlights: [][]term.FormattedChunk{{{Start: 5, Length: 2}}, {{Start: 2, Length: 2}, {Start: 10, Length: 2}}, nil}},
+			},
+		},
+		{
+			msg:             "descriptions",
+			query:           "^list",
+			caseInsensitive: true,
+			want: []result{
+				{Invocation: "kubectl get pods", Highlights: [][]term.FormattedChunk{nil, {{Start: 0, Length: 4}}, nil}},
+			},
+		},
+		{
+			msg:   "tags",
+			query: "#k8s|pods$",
+			want: []result{
+				{Invocation: "kubectl get pods", Highlights: [][]term.FormattedChunk{{{Start: 12, Length: 4}}, {{Start: 5, Length: 4}}, {{Start: 0, Length: 4}, {Start: 6, Length: 4}}}},
+			},
+		},
+		{
+			msg:             "case insensitive",
+			query:           "graph",
+			caseInsensitive: true,
+			want: []result{
+				{Invocation: "git log --graph", Highlights: [][]term.FormattedChunk{{{Start: 10, Length: 5}}, {{Start: 16, Length: 5}}, nil}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			toggles := s.Toggles()
+			if len(toggles) != 1 {
+				t.Fatalf("Got %d toggles, want 1", len(toggles))
+			}
+			if toggles[0].Enabled() != tt.caseInsensitive {
+				toggles[0].Flip()
+			}
+
+			if diff := cmp.Diff(search(tt.query), tt.want); diff != "" {
+				t.Errorf("Results diff (-got, +want):\n%s", diff)
+			}
+		})
+	}
+
+	_, err := s.Search("(git")
+	if !errors.Is(err, term.ErrQueryableListInvalidQuery) || !strings.Contains(err.Error(), "missing closing )") {
+		t.Errorf("Got error %v for an invalid regex, want an invalid query error with the reason", err)
+	}
+}
+
+func TestSearchModes(t *testing.T) {
+	commands := []*Command{
+		{Invocation: "git log --graph"},
+		{Invocation: "go generate ./..."},
+		{Invocation: "docker logs -f api"},
+	}
+	c := &Container{states: []*state{{Commands: commands}}}
+	s := c.Searcher(SearchOptions{})
+
+	tests := []struct {
+		mode  string
+		query string
+		want  []string
+	}{
+		{mode: "fuzzy", query: "gog", want: []string{"go generate ./...", "git log --graph"}},
+		{mode: "exact", query: "log", want: []string{"git log --graph", "docker logs -f api"}},
+		{mode: "regex", query: "^g.*h$", want: []string{"git log --graph"}},
+		{mode: "prefix", query: "go ", want: []string{"go generate ./..."}},
+		{mode: "fuzzy", query: "gog", want: []string{"go generate ./...", "git log --graph"}},
+	}
+
+	for i, tt := range tests {
+		if i > 0 {
+			s.NextMode()
+		}
+		if got := s.Mode(); got != tt.mode {
+			t.Fatalf("Mode %d is %q, want %q", i, got, tt.mode)
+		}
+
+		items, err := s.Search(tt.query)
+		if err != nil {
+			t.Fatalf("Unexpected search error in %s mode: %v", tt.mode, err)
+		}
+		var got []string
+		for _, item := range items {
+			got = append(got, item.Raw.Invocation)
+		}
+		if diff := cmp.Diff(got, tt.want); diff != "" {
+			t.Errorf("Results in %s mode diff (-got, +want):\n%s", tt.mode, diff)
+		}
+	}
+}
+
+func TestMatchLiteral(t *testing.T) {
+	tests := []struct {
+		kind    termKind
+		needle  string
+		src     string
+		matches []matchedText
+	}{
+		{kind: termExact, needle: "log", src: "git log", matches: []matchedText{{start: 4, length: 3}}},
+		{kind: termExact, needle: "LOG", src: "  git Log  ", matches: []matchedText{{start: 6, length: 3}}},
+		{kind: termExact, needle: "og", src: "git log", matches: []matchedText{{start: 5, length: 2}}},
+		{kind: termExact, needle: "gl", src: "git log"},
+		{kind: termExact, needle: "log", src: "catalog log", matches: []matchedText{{start: 8, length: 3}}},
+		{kind: termPrefix, needle: "git", src: " git log", matches: []matchedText{{start: 1, length: 3}}},
+		{kind: termPrefix, needle: "log", src: "git log"},
+		{kind: termSuffix, needle: "log", src: "git log ", matches: []matchedText{{start: 4, length: 3}}},
+		{kind: termSuffix, needle: "git", src: "git log"},
+		{kind: termSuffix, needle: "é", src: "café", matches: []matchedText{{start: 3, length: 2}}},
+	}
+
+	for _, tt := range tests {
+		matches, _ := matchLiteral(tt.kind, tt.needle, tt.src, false)
+		if diff := cmp.Diff(matches, tt.matches, cmp.AllowUnexported(matchedText{})); diff != "" {
+			t.Errorf("Matches of %q (kind %d) in %q diff (-got, +want):\n%s", tt.needle, tt.kind, tt.src, diff)
+		}
+	}
+}
+
+func TestIncrementalSearch(t *testing.T) {
+	c, err := initialize(filepath.Join(t.TempDir(), "speeddial.json"))
+	if err != nil {
+		t.Fatalf("Unable to initialize the container: %v", err)
+	}
+	for _, command := range benchmarkState(2000).Commands {
+		if err := c.AddCommandIn(PersonalSource, &Command{Invocation: command.Invocation, Description: command.Description}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	invocations := func(s *Searcher, query string) []string {
+		t.Helper()
+		items, err := s.Search(query)
+		if err != nil {
+			t.Fatalf("Unexpected search error for %q: %v", query, err)
+		}
+		var got []string
+		for _, item := range items {
+			got = append(got, item.Raw.Invocation)
+		}
+		return got
+	}
+
+	// Each query is searched with a searcher that has seen the previous queries and compared
+	// against a fresh searcher
+	s := c.Searcher(SearchOptions{})
+	for _, query := range []string{"g", "gi", "git", "git l", "git lo", "git log", "git lo", "#x", "kub", "kubectl pods"} {
+		got := invocations(s, query)
+		if diff := cmp.Diff(got, invocations(c.Searcher(SearchOptions{}), query)); diff != "" {
+			t.Errorf("Incremental search for %q diff (-got, +want):\n%s", query, diff)
+		}
+	}
+
+	// Modifying the container invalidates previous results
+	invocations(s, "git")
+	if err := c.AddCommandIn(PersonalSource, &Command{Invocation: "git stash pop"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := invocations(s, "git stash"); !slices.Contains(got, "git stash pop") {
+		t.Errorf("Search for %q after adding a command returned %v, want it to include %q", "git stash", got, "git stash pop")
+	}
+}
+
+func TestMatchAll(t *testing.T) {
+	s := benchmarkState(3*searchChunkSize + 7)
+	m := fuzzyMatcher(parseQuery("git log"))
+
+	var want []*Command
+	for _, c := range s.Commands {
+		if _, ok := m(c); ok {
+			want = append(want, c)
+		}
+	}
+
+	matches, err := matchAll(context.Background(), s.Commands, m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var got []*Command
+	for _, mc := range matches {
+		got = append(got, mc.c)
+	}
+	if len(want) == 0 || !slices.Equal(got, want) {
+		t.Errorf("Concurrent search matched %d commands, want the %d sequentially matched commands in order", len(got), len(want))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := matchAll(ctx, s.Commands, m); err != context.Canceled {
+		t.Errorf("Got error %v from a cancelled search, want %v", err, context.Canceled)
+	}
+}
+
+// benchmarkPipeline is a realistic long command, which is the worst case for matching.
+const benchmarkPipeline = `kubectl get pods --all-namespaces -o json | jq -r '.items[] | select(.status.phase != "Running") | [.metadata.namespace, .metadata.name, .status.phase] | @tsv' | sort -k1,1 | column -t | tee /tmp/pods.txt`
+
+// benchmarkState generates a state with n commands made up of common tools, subcommands and
+// flags, including some long pipelines.
+func benchmarkState(n int) *state {
+	r := rand.New(rand.NewSource(1))
+	tools := []string{"kubectl", "git", "docker", "go", "terraform", "aws s3", "gcloud compute", "find . -name"}
+	words := []string{"get", "log", "build", "deploy", "--namespace", "production", "--graph", "--oneline", "-o wide", "| grep error", "| sort | uniq -c", "--filter", "status", "instances", "*.go", "| xargs rm"}
+
+	s := &state{}
+	for i := 0; i < n; i++ {
+		parts := []string{tools[r.Intn(len(tools))]}
+		for j := 0; j < 2+r.Intn(8); j++ {
+			parts = append(parts, words[r.Intn(len(words))])
+		}
+		inv := strings.Join(parts, " ")
+		if i%50 == 0 {
+			inv = benchmarkPipeline
+		}
+		s.Commands = append(s.Commands, &Command{Invocation: inv, Description: fmt.Sprintf("Command number %d", i), state: s})
+	}
+	return s
+}
+
+func BenchmarkMatchLongPipeline(b *testing.B) {
+	q := parseQuery("pods status")
+	for i := 0; i < b.N; i++ {
+		match(q, benchmarkPipeline)
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	s := benchmarkState(5000)
+	for _, query := range []string{"k", "kubectl", "git log graph", "pods status"} {
+		m := fuzzyMatcher(parseQuery(query))
+		b.Run(query, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := matchAll(context.Background(), s.Commands, m); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
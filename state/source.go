@@ -0,0 +1,180 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// This path is relative to the user's home directory.
+	configPath = ".config/speeddial/config.json"
+
+	// The name of per-project state files, which are found by walking up from the working directory.
+	projectStateFile = ".speeddial.json"
+)
+
+// Names of the built-in sources.
+const (
+	PersonalSource = "personal"
+	ProjectSource  = "project"
+)
+
+// Source describes a state file that can be loaded into a container. Commands can only be added to,
+// changed in, or deleted from writable sources.
+type Source struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Writable bool   `json:"writable"`
+}
+
+// config is the user's speeddial configuration. The personal state is always loaded and writable.
+type config struct {
+	// Additional state files to load, such as a team's shared commands.
+	Sources []Source `json:"sources"`
+	// The source that new commands are added to if none is specified. This defaults to the personal
+	// state.
+	Default string        `json:"default"`
+	Project projectConfig `json:"project"`
+}
+
+// projectConfig controls how per-project state files are handled.
+type projectConfig struct {
+	Disabled bool `json:"disabled"`
+	Writable bool `json:"writable"`
+}
+
+// loadConfig reads the config file at the given path. A missing file results in the default config.
+func loadConfig(path string, home string) (*config, error) {
+	var cfg config
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &cfg, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse the config at %s: %v", path, err)
+	}
+
+	names := map[string]bool{PersonalSource: true, ProjectSource: true}
+	for i, src := range cfg.Sources {
+		if src.Name == "" || src.Path == "" {
+			return nil, fmt.Errorf("every source in the config at %s needs a name and a path", path)
+		} else if names[src.Name] {
+			return nil, fmt.Errorf("the source name %q in the config at %s is reserved or used multiple times", src.Name, path)
+		}
+		names[src.Name] = true
+
+		cfg.Sources[i].Path = expandHome(src.Path, home)
+	}
+
+	return &cfg, nil
+}
+
+// expandHome replaces a leading ~ in path with the user's home directory.
+func expandHome(path, home string) string {
+	if path == "~" {
+		return home
+	} else if strings.HasPrefix(path, "~/") {
+		return filepath.Join(home, path[2:])
+	}
+	return path
+}
+
+// findProjectFile walks up from dir looking for a per-project state file, returning an empty
+// string if none is found.
+func findProjectFile(dir string) string {
+	for {
+		path := filepath.Join(dir, projectStateFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadSources loads the sources from the config as well as the per-project state file, if any.
+// Read-only sources which do not exist are skipped with a warning.
+func (c *Container) loadSources(cfg *config, wd string) error {
+	sources := cfg.Sources
+	if !cfg.Project.Disabled && wd != "" {
+		if path := findProjectFile(wd); path != "" {
+			sources = append(sources, Source{Name: ProjectSource, Path: path, Writable: cfg.Project.Writable})
+		}
+	}
+
+	for _, src := range sources {
+		if c.hasPath(src.Path) {
+			continue
+		}
+
+		if _, err := os.Stat(src.Path); !src.Writable && errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Skipping the %q source as %s does not exist\n", src.Name, src.Path)
+			continue
+		}
+
+		if err := c.Load(src); err != nil {
+			return fmt.Errorf("unable to load the %q source: %v", src.Name, err)
+		}
+	}
+
+	if cfg.Default != "" {
+		s := c.source(cfg.Default)
+		if s == nil {
+			return fmt.Errorf("the default source %q was not loaded", cfg.Default)
+		} else if !s.writable {
+			return fmt.Errorf("the default source %q is not writable", cfg.Default)
+		}
+		c.defaultSource = cfg.Default
+	}
+
+	return nil
+}
+
+// Sources returns the names of all loaded sources.
+func (c *Container) Sources() []string {
+	names := make([]string, 0, len(c.states))
+	for _, s := range c.states {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+func (c *Container) hasPath(path string) bool {
+	path = filepath.Clean(path)
+	for _, s := range c.states {
+		if s.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// source returns the state with the given name, or nil if there is no such state.
+func (c *Container) source(name string) *state {
+	for _, s := range c.states {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Source returns the name of the source that the command belongs to.
+func (c *Command) Source() string {
+	if c.state == nil {
+		return ""
+	}
+	return c.state.name
+}
@@ -0,0 +1,212 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		msg     string
+		content string
+		want    *config
+		wantErr bool
+	}{
+		{
+			msg:  "missing config",
+			want: &config{},
+		},
+		{
+			msg:     "sources with home directory expansion",
+			content: `{"default": "team", "sources": [{"name": "team", "path": "~/team/speeddial.json", "writable": true}, {"name": "ops", "path": "/srv/ops.json"}], "project": {"writable": true}}`,
+			want: &config{
+				Default: "team",
+				Sources: []Source{
+					{Name: "team", Path: "/home/user/team/speeddial.json", Writable: true},
+					{Name: "ops", Path: "/srv/ops.json"},
+				},
+				Project: projectConfig{Writable: true},
+			},
+		},
+		{
+			msg:     "reserved source name",
+			content: `{"sources": [{"name": "personal", "path": "/srv/ops.json"}]}`,
+			wantErr: true,
+		},
+		{
+			msg:     "duplicate source name",
+			content: `{"sources": [{"name": "ops", "path": "/srv/a.json"}, {"name": "ops", "path": "/srv/b.json"}]}`,
+			wantErr: true,
+		},
+		{
+			msg:     "missing path",
+			content: `{"sources": [{"name": "ops"}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, err := loadConfig(path, "/home/user")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error when loading the config")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("Unable to load the config: %v", err)
+			}
+
+			if diff := cmp.Diff(cfg, tt.want, cmp.AllowUnexported(config{})); diff != "" {
+				t.Errorf("Config diff (-got, +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSources(t *testing.T) {
+	dir := t.TempDir()
+	teamPath := filepath.Join(dir, "team.json")
+	projectDir := filepath.Join(dir, "project", "nested", "deeper")
+	projectPath := filepath.Join(dir, "project", projectStateFile)
+
+	if err := os.MkdirAll(projectDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{teamPath, projectPath} {
+		raw, err := encodeState(&state{Commands: []*Command{{Invocation: filepath.Base(path)}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, raw, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := findProjectFile(projectDir); got != projectPath {
+		t.Errorf("findProjectFile returned %q, want %q", got, projectPath)
+	}
+
+	c, err := initialize(filepath.Join(dir, "personal.json"))
+	if err != nil {
+		t.Fatalf("Unable to initialize the container: %v", err)
+	}
+
+	cfg := &config{
+		Default: ProjectSource,
+		Sources: []Source{
+			{Name: "team", Path: teamPath},
+			{Name: "missing", Path: filepath.Join(dir, "missing.json")},
+		},
+		Project: projectConfig{Writable: true},
+	}
+	if err := c.loadSources(cfg, projectDir); err != nil {
+		t.Fatalf("Unable to load the sources: %v", err)
+	}
+
+	if diff := cmp.Diff(c.Sources(), []string{PersonalSource, "team", ProjectSource}); diff != "" {
+		t.Errorf("Sources diff (-got, +want):\n%s", diff)
+	}
+
+	if err := c.NewCommand("make test", "", nil); err != nil {
+		t.Errorf("Unable to add a command to the default source: %v", err)
+	}
+	if err := c.NewCommandIn(PersonalSource, "ls", "", nil); err != nil {
+		t.Errorf("Unable to add a command to the personal source: %v", err)
+	}
+	if err := c.NewCommandIn("team", "ls", "", nil); err == nil {
+		t.Errorf("Expected an error when adding a command to a read-only source")
+	}
+	if err := c.NewCommandIn("unknown", "ls", "", nil); err == nil {
+		t.Errorf("Expected an error when adding a command to an unknown source")
+	}
+	if err := c.DeleteCommand(c.source("team").Commands[0]); err == nil {
+		t.Errorf("Expected an error when deleting a command from a read-only source")
+	}
+
+	var got [][]string
+	for _, command := range c.List() {
+		got = append(got, []string{command.Source(), command.Invocation})
+	}
+	want := [][]string{
+		{PersonalSource, "ls"},
+		{"team", "team.json"},
+		{ProjectSource, projectStateFile},
+		{ProjectSource, "make test"},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Commands diff (-got, +want):\n%s", diff)
+	}
+
+	// Read-only sources should not be written to.
+	before, err := os.ReadFile(teamPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.source("team").Commands = nil
+	c.Dump()
+	after, err := os.ReadFile(teamPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Errorf("A read-only source was modified by Dump")
+	}
+
+	if err := c.loadSources(&config{Default: "team"}, ""); err == nil {
+		t.Errorf("Expected an error when the default source is read-only")
+	}
+}
+
+func TestLoadReadOnlySource(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "team")
+	path := filepath.Join(dir, "speeddial.json")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := encodeState(&state{Commands: []*Command{{Invocation: "make deploy"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, raw, 0o444); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chmod(dir, 0o755)
+	})
+
+	c := Container{}
+	if err := c.Load(Source{Name: "team", Path: path}); err != nil {
+		t.Fatalf("Unable to load a read-only source from a read-only directory: %v", err)
+	}
+	if got := c.source("team").Commands; len(got) != 1 || got[0].Invocation != "make deploy" {
+		t.Errorf("Got commands %v from the read-only source, want only \"make deploy\"", got)
+	}
+
+	// Nothing, not even a lock file, should be created next to a read-only source
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if diff := cmp.Diff(names, []string{"speeddial.json"}); diff != "" {
+		t.Errorf("Files next to the read-only source diff (-got, +want):\n%s", diff)
+	}
+}
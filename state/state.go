@@ -1,39 +1,66 @@
 package state
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"golang.org/x/exp/slices"
 )
 
 const (
 	dumpVersion1 = 1
+	dumpVersion2 = 2
+
+	currentDumpVersion = dumpVersion2
 
 	// This path is relative to the user's home directory.
 	primaryStatePath = ".config/speeddial/state.json"
+
+	// The suffix added to a state's path to get the path of its lock file.
+	lockSuffix = ".lock"
 )
 
 // Command is a fundamental unit that is some string that can be run in a shell along with
 // additional metadata.
 type Command struct {
-	Invocation  string `json:"i"`
-	Description string `json:"d"`
+	Invocation  string   `json:"i"`
+	Description string   `json:"d"`
+	Tags        []string `json:"t,omitempty"`
+	// Usage is nil if the command has never been used.
+	Usage *Usage `json:"u,omitempty"`
 
-	// This field is lazily set during search.
+	// This field is set when the command is loaded or created.
 	state *state
+	// The invocation of the command when it was last loaded from or written to disk. It is empty for
+	// commands that have not yet been written out and is used to merge concurrent modifications.
+	loadedInvocation string
+}
+
+// Usage tracks how often and how recently a command has been used.
+type Usage struct {
+	Count int       `json:"n"`
+	Last  time.Time `json:"l"`
 }
 
 // state is made up primarily of a set of commands. A state is the module that is stored persistently
 // and can be shared.
 type state struct {
-	primary  bool
+	name     string
 	path     string
+	writable bool
+	// The raw contents of the file when it was last loaded or written, used to detect changes made
+	// by other processes.
+	loaded   []byte
 	Commands []*Command `json:"c"`
+	// Recently used placeholder values, keyed by placeholder name.
+	Recent map[string][]string `json:"r,omitempty"`
 }
 
 // dump is a wrapper around state that is persisted and saved to a file for use across invocations
@@ -44,56 +71,74 @@ type dump struct {
 	Data    *state `json:"d"`
 }
 
+// now is used in place of time.Now so that tests can control the current time.
+var now = time.Now
+
 // Container encapsulates the various states loaded.
+//
+// State files are locked while they are being read or written, but not in between, so other
+// processes may modify them while a container is in use. Such modifications are merged into the
+// container's state when it is dumped.
 type Container struct {
 	states []*state
+	// The name of the source that new commands are added to by default.
+	defaultSource string
+	// generation is incremented whenever commands are added, updated or deleted so that cached
+	// search results can be invalidated.
+	generation int
 }
 
-// initFile creates a new speeddial state file at the given path.
+// initFile creates a new speeddial state file at the given path. The caller should hold the lock for
+// the file.
 func initFile(path string) error {
 	// TODO: Handle the case where something else already uses ~/.config/speeddial
-	dir, _ := filepath.Split(path)
-	err := os.MkdirAll(dir, 0o755)
+	raw, err := encodeState(&state{})
 	if err != nil {
 		return err
 	}
+	return writeFileAtomic(path, raw)
+}
 
-	f, err := os.Create(path)
+// Init initializes the state container, loading in the personal state file as well as any
+// additional sources from the user's config and the per-project state file, if one exists.
+func Init() (*Container, error) {
+	u, err := user.Current()
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("unable to fetch your home directory: %v", err)
 	}
-	defer f.Close()
 
-	d := dump{
-		Version: dumpVersion1,
-		Data:    &state{},
+	cfg, err := loadConfig(filepath.Join(u.HomeDir, configPath), u.HomeDir)
+	if err != nil {
+		return nil, err
 	}
-	return json.NewEncoder(f).Encode(&d)
-}
 
-// Init initializes the state container, also loading in the primary state file.
-func Init() (*Container, error) {
-	u, err := user.Current()
+	c, err := initialize(filepath.Join(u.HomeDir, primaryStatePath))
 	if err != nil {
-		return nil, fmt.Errorf("unable to fetch your home directory: %v", err)
+		return nil, err
+	}
+
+	// The working directory is only used to find a per-project state file, so this is best-effort.
+	wd, _ := os.Getwd()
+	if err := c.loadSources(cfg, wd); err != nil {
+		return nil, err
 	}
-	return initialize(filepath.Join(u.HomeDir, primaryStatePath))
+
+	return c, nil
 }
 
+// initialize creates a container with just the personal state at the given path loaded.
 func initialize(statePath string) (*Container, error) {
-	var c Container
+	c := Container{defaultSource: PersonalSource}
 
-	err := c.Load(statePath)
+	err := c.Load(Source{Name: PersonalSource, Path: statePath, Writable: true})
 	if err != nil {
-		return nil, fmt.Errorf("unable to load your primary speeddial state: %v", err)
+		return nil, fmt.Errorf("unable to load your personal speeddial state: %v", err)
 	}
 
-	// TODO: Fix this hack
-	c.states[0].primary = true
-
 	return &c, nil
 }
 
+// List returns every command in the container.
 func (c *Container) List() []*Command {
 	var commands []*Command
 	for _, s := range c.states {
@@ -102,81 +147,264 @@ func (c *Container) List() []*Command {
 	return commands
 }
 
-// Load loads the speeddial state at the given path into the provided container, creating a new one
-// if one does not exist.
-func (c *Container) Load(path string) error {
-	path = filepath.Clean(path)
+// Load loads the speeddial state for the given source into the provided container. If the source is
+// writable and its file does not exist, a new one is created. Read-only sources are only read, so
+// they can live in directories that are not writable (e.g. a shared repository).
+func (c *Container) Load(src Source) error {
+	path := filepath.Clean(src.Path)
+	if c.source(src.Name) != nil {
+		return fmt.Errorf("a source named %q has already been loaded", src.Name)
+	}
 
-	_, err := os.Stat(path)
-	if errors.Is(err, os.ErrNotExist) {
-		if err := initFile(path); err != nil {
+	var raw []byte
+	var err error
+	if src.Writable {
+		var unlock func() error
+		raw, unlock, err = readWritable(path)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	} else {
+		raw, err = os.ReadFile(path)
+		if err != nil {
 			return err
 		}
-	} else if err != nil {
-		return err
 	}
 
-	f, err := os.Open(path)
+	// Older files are backed up before being rewritten with the current version so that the
+	// original can be recovered if a migration goes wrong. Read-only files are only migrated in
+	// memory, as they are not ours to rewrite.
+	upgraded, version, notes, err := migrate(raw, path)
 	if err != nil {
 		return err
+	} else if version != currentDumpVersion && !src.Writable {
+		for _, note := range notes {
+			fmt.Fprintf(os.Stderr, "While migrating the state at %s: %s\n", path, note)
+		}
+		raw = upgraded
+	} else if version != currentDumpVersion {
+		backup, err := backupFile(path, raw, version)
+		if err != nil {
+			return fmt.Errorf("unable to back up the state at %s before migrating it: %v", path, err)
+		}
+		if err := writeFileAtomic(path, upgraded); err != nil {
+			return fmt.Errorf("unable to write the migrated state to %s: %v", path, err)
+		}
+		for _, note := range notes {
+			fmt.Fprintf(os.Stderr, "While migrating the state at %s (backed up to %s): %s\n", path, backup, note)
+		}
+		raw = upgraded
 	}
-	defer f.Close()
 
-	var d dump
-	if err := json.NewDecoder(f).Decode(&d); err != nil {
+	s, err := decodeState(raw, path)
+	if err != nil {
 		return err
 	}
+	s.name = src.Name
+	s.writable = src.Writable
+	s.loaded = raw
 
-	if d.Version < dumpVersion1 {
-		return fmt.Errorf("%d is an unsupported version for state at %s", d.Version, path)
-	}
+	c.states = append(c.states, s)
 
-	s := d.Data
-	if s == nil {
-		return fmt.Errorf("dump at %s does not have any state", path)
+	return nil
+}
+
+// readWritable reads the state file at path, creating it (and its directory) if it does not exist.
+// The file is returned locked, and the caller should unlock it once it is done with the file.
+func readWritable(path string) ([]byte, func() error, error) {
+	dir, _ := filepath.Split(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
 	}
 
-	s.path = path
+	unlock, err := lockFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to lock the state at %s: %v", path, err)
+	}
 
-	c.states = append(c.states, s)
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		err = initFile(path)
+	}
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
 
-	return nil
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return raw, unlock, nil
 }
 
-// Dump stores the contents of every state to disk.
+// Dump stores the contents of every writable state to disk.
 func (c *Container) Dump() {
 	for _, s := range c.states {
-		f, err := os.Create(s.path)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Unable to open the file at %s to dump state: %v\n", s.path, err)
+		if !s.writable {
 			continue
 		}
-
-		d := dump{
-			Version: dumpVersion1,
-			Data:    s,
-		}
-		if err := json.NewEncoder(f).Encode(&d); err != nil {
+		if err := s.dump(); err != nil {
 			fmt.Fprintf(os.Stderr, "Unable to dump the state at %s: %v\n", s.path, err)
-			f.Close()
-			continue
 		}
-		f.Close()
 	}
 }
 
-// NewCommand creates a new command in the primary state with the given invocation string and
-// description.
-func (c *Container) NewCommand(invocation, desc string) error {
-	for _, s := range c.states {
-		if !s.primary {
-			continue
+// dump writes the state to disk while holding the lock for its file. If the file was changed by
+// another process since it was loaded, those changes are first merged into the state. The file is
+// replaced atomically so that it is never left partially written.
+func (s *state) dump() error {
+	unlock, err := lockFile(s.path)
+	if err != nil {
+		return fmt.Errorf("unable to lock the file: %v", err)
+	}
+	defer unlock()
+
+	current, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err == nil && !bytes.Equal(current, s.loaded) {
+		base, err := decodeState(s.loaded, s.path)
+		if err != nil {
+			return fmt.Errorf("unable to decode the originally loaded state: %v", err)
 		}
-		s.newCommand(invocation, desc)
-		return nil
+		theirs, err := decodeState(current, s.path)
+		if err != nil {
+			return fmt.Errorf("unable to decode the state that was changed on disk: %v", err)
+		}
+		s.merge(base, theirs)
 	}
 
-	return errors.New("there is no main state to which the new command should be added")
+	raw, err := encodeState(s)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, raw); err != nil {
+		return err
+	}
+
+	s.loaded = raw
+	for _, command := range s.Commands {
+		command.loadedInvocation = command.Invocation
+	}
+	return nil
+}
+
+// decodeState decodes a dump that was read from the file at path, migrating it to the current
+// version if necessary.
+func decodeState(raw []byte, path string) (*state, error) {
+	raw, _, _, err := migrate(raw, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var d dump
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return nil, err
+	}
+
+	s := d.Data
+	if s == nil {
+		return nil, fmt.Errorf("dump at %s does not have any state", path)
+	}
+
+	s.path = path
+	for _, command := range s.Commands {
+		command.state = s
+		command.loadedInvocation = command.Invocation
+	}
+
+	return s, nil
+}
+
+// encodeState encodes the state as a dump with the current version.
+func encodeState(s *state) ([]byte, error) {
+	var buf bytes.Buffer
+	d := dump{
+		Version: currentDumpVersion,
+		Data:    s,
+	}
+	if err := json.NewEncoder(&buf).Encode(&d); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as path and then renames it
+// over path, so that readers see either the old or the new contents but never a partial write.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	dir, name := filepath.Split(path)
+	f, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, mode)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// NewCommand creates a new command in the default source with the given invocation string,
+// description and tags.
+func (c *Container) NewCommand(invocation, desc string, tags []string) error {
+	return c.NewCommandIn(c.defaultSource, invocation, desc, tags)
+}
+
+// NewCommandIn creates a new command in the named source with the given invocation string,
+// description and tags.
+func (c *Container) NewCommandIn(source, invocation, desc string, tags []string) error {
+	return c.AddCommandIn(source, &Command{
+		Invocation:  invocation,
+		Description: desc,
+		Tags:        tags,
+	})
+}
+
+// AddCommandIn adds an existing command, such as one that was imported, to the named source.
+func (c *Container) AddCommandIn(source string, command *Command) error {
+	s := c.source(source)
+	if s == nil {
+		return fmt.Errorf("there is no source named %q to which the new command should be added", source)
+	} else if !s.writable {
+		return fmt.Errorf("the %q source is not writable", source)
+	} else if strings.TrimSpace(command.Invocation) == "" {
+		return errors.New("the invocation cannot be empty")
+	}
+
+	s.addCommand(command)
+	c.generation++
+	return nil
+}
+
+// DefaultSource returns the name of the source that new commands are added to by default.
+func (c *Container) DefaultSource() string {
+	return c.defaultSource
 }
 
 // DeleteCommand deletes the given command from the container.
@@ -187,6 +415,10 @@ func (c *Container) DeleteCommand(command *Command) error {
 
 	found := false
 	s := command.state
+	if !s.writable {
+		return fmt.Errorf("command %q belongs to the %q source, which is not writable", command.Invocation, s.name)
+	}
+
 	for i, sc := range s.Commands {
 		if sc == command {
 			s.Commands = slices.Delete(s.Commands, i, i+1)
@@ -199,14 +431,70 @@ func (c *Container) DeleteCommand(command *Command) error {
 		return fmt.Errorf("command %q was not found in the state", command.Invocation)
 	}
 
+	c.generation++
+	return nil
+}
+
+// RecordUse records that the command has just been used, which is taken into account when ranking
+// search results.
+func (c *Command) RecordUse() {
+	if c.Usage == nil {
+		c.Usage = &Usage{}
+	}
+	c.Usage.Count++
+	c.Usage.Last = now().UTC().Truncate(time.Second)
+}
+
+// tagString formats the command's tags for display, prefixing each tag with '#'.
+func (c *Command) tagString() string {
+	tags := make([]string, 0, len(c.Tags))
+	for _, t := range c.Tags {
+		tags = append(tags, "#"+t)
+	}
+	return strings.Join(tags, " ")
+}
+
+// UpdateCommand replaces the invocation, description and tags of the given command, keeping its
+// position and usage history.
+func (c *Container) UpdateCommand(command *Command, invocation, desc string, tags []string) error {
+	if command.state == nil {
+		return fmt.Errorf("command %q did not have a corresponding state", command.Invocation)
+	} else if !command.state.writable {
+		return fmt.Errorf("command %q belongs to the %q source, which is not writable", command.Invocation, command.state.name)
+	} else if !slices.Contains(command.state.Commands, command) {
+		return fmt.Errorf("command %q was not found in the state", command.Invocation)
+	} else if strings.TrimSpace(invocation) == "" {
+		return errors.New("the invocation cannot be empty")
+	}
+
+	command.Invocation = invocation
+	command.Description = desc
+	command.Tags = NormalizeTags(tags)
+	c.generation++
 	return nil
 }
 
-func (s *state) newCommand(invocation, desc string) {
-	var c Command
-	c.Invocation = invocation
-	c.Description = desc
+func (s *state) addCommand(c *Command) {
+	c.Invocation = strings.TrimSpace(c.Invocation)
+	c.Tags = NormalizeTags(c.Tags)
+	c.state = s
+	c.loadedInvocation = ""
 
-	// TODO: Check for duplicates
-	s.Commands = append(s.Commands, &c)
+	// Duplicates are allowed so that the user can choose to keep both: callers should use
+	// FindDuplicate to check for them first.
+	s.Commands = append(s.Commands, c)
+}
+
+// NormalizeTags cleans up user-provided tags, removing any leading '#', surrounding whitespace,
+// empty tags and duplicates. Tags are case-insensitive and are stored in lowercase.
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(t), "#"))
+		if t == "" || slices.Contains(normalized, t) {
+			continue
+		}
+		normalized = append(normalized, t)
+	}
+	return normalized
 }
@@ -11,9 +11,9 @@ import (
 
 func TestStateOperations(t *testing.T) {
 	commands := []*Command{
-		{"git push", "Push changes", nil},
-		{"git commit", "Commit changes", nil},
-		{"git add", "Stage changes", nil},
+		{Invocation: "git push", Description: "Push changes", Tags: []string{"git"}},
+		{Invocation: "git commit", Description: "Commit changes"},
+		{Invocation: "git add", Description: "Stage changes", Tags: []string{"git", "staging"}},
 	}
 
 	less := func(a, b *Command) bool {
@@ -55,8 +55,8 @@ func TestStateOperations(t *testing.T) {
 		t.Errorf("Unable to check if a config file was created: %v", err)
 	}
 
-	checkNoErr(c1.NewCommand(commands[0].Invocation, commands[0].Description))
-	checkNoErr(c1.NewCommand(commands[1].Invocation, commands[1].Description))
+	checkNoErr(c1.NewCommand(commands[0].Invocation, commands[0].Description, commands[0].Tags))
+	checkNoErr(c1.NewCommand(commands[1].Invocation, commands[1].Description, commands[1].Tags))
 
 	c1.Dump()
 
@@ -69,11 +69,48 @@ func TestStateOperations(t *testing.T) {
 
 	checkCommands(c2.List(), commands[:2])
 
-	checkNoErr(c2.NewCommand(commands[2].Invocation, commands[2].Description))
+	checkNoErr(c2.NewCommand(commands[2].Invocation, commands[2].Description, commands[2].Tags))
 	checkCommands(c2.List(), commands[:3])
 
 	c2.Dump()
 
-	// TODO: Check for duplicates once duplicate detection is added. Also test loading multiple
-	// configs
+	// Re-adding a command with different whitespace and quoting should be detected as a duplicate
+	if got := c2.FindDuplicate(`git   'push'`); got == nil || got.Invocation != commands[0].Invocation {
+		t.Errorf("FindDuplicate returned %v, want the %q command", got, commands[0].Invocation)
+	}
+}
+
+func TestUpdateCommand(t *testing.T) {
+	c, err := initialize(filepath.Join(t.TempDir(), "speeddial.json"))
+	if err != nil {
+		t.Fatalf("Unable to initialize the container: %v", err)
+	}
+
+	for _, inv := range []string{"git push", "git comit", "git add"} {
+		if err := c.NewCommand(inv, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	command := c.List()[1]
+	command.RecordUse()
+	if err := c.UpdateCommand(command, "git commit", "Commit changes", []string{"#Git"}); err != nil {
+		t.Errorf("Unable to update the command: %v", err)
+	}
+
+	want := []*Command{
+		{Invocation: "git push"},
+		{Invocation: "git commit", Description: "Commit changes", Tags: []string{"git"}, Usage: command.Usage},
+		{Invocation: "git add"},
+	}
+	if diff := cmp.Diff(c.List(), want, cmpopts.IgnoreUnexported(Command{})); diff != "" {
+		t.Errorf("Unexpected commands after the update (-got, +want):\n%s", diff)
+	}
+
+	if err := c.UpdateCommand(command, " ", "", nil); err == nil {
+		t.Errorf("Expected an error when updating a command with an empty invocation")
+	}
+	if err := c.UpdateCommand(&Command{Invocation: "ls"}, "ls -la", "", nil); err == nil {
+		t.Errorf("Expected an error when updating a command that is not in the container")
+	}
 }
@@ -0,0 +1,46 @@
+package term
+
+import "errors"
+
+// ErrClosedByAction is returned by List when an action closes the list.
+var ErrClosedByAction = errors.New("the list was closed by an action")
+
+// Action is a key binding in a list that acts on the selected item, such as deleting it. Actions
+// take precedence over the list's other key bindings, including those for editing the query.
+type Action[T any] struct {
+	// Key triggers the action. It should not be one of the query editor's keys (e.g. "ctrl-e"),
+	// which it would override.
+	Key Key
+	// Confirm, if set, returns a question (e.g. "Delete this item?") that is shown below the query.
+	// The action only runs if the user then presses "y".
+	Confirm func(item ListItem[T]) string
+	// Suspend clears the list and restores the terminal while the action runs, so that the action
+	// can use the terminal itself (e.g. to show a form or run a command).
+	Suspend bool
+	// Run performs the action on the selected item. Errors are shown to the user, after which the
+	// list remains open.
+	Run func(item ListItem[T]) (ActionResult, error)
+}
+
+// ActionResult tells the list what to do after an action has run.
+type ActionResult struct {
+	// Refresh searches again with the current query, e.g. because the item was modified.
+	Refresh bool
+	// Close closes the list, which then returns ErrClosedByAction.
+	Close bool
+	// Message is shown below the query until the next key press.
+	Message string
+}
+
+// findAction returns the action that is bound to the key, if any.
+func findAction[T any](actions []Action[T], e *Event) *Action[T] {
+	if e.key == KeyChar || e.mod != 0 {
+		return nil
+	}
+	for i := range actions {
+		if actions[i].Key == e.key {
+			return &actions[i]
+		}
+	}
+	return nil
+}
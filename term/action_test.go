@@ -0,0 +1,77 @@
+package term
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindAction(t *testing.T) {
+	actions := []Action[string]{{Key: KeyCtrlD}, {Key: KeyCtrlY}}
+
+	tests := []struct {
+		msg   string
+		event Event
+		want  int
+	}{
+		{msg: "bound key", event: Event{key: KeyCtrlY}, want: 1},
+		{msg: "unbound key", event: Event{key: KeyCtrlX}, want: -1},
+		{msg: "characters are never bound", event: Event{key: KeyChar, char: 'd'}, want: -1},
+		{msg: "modifiers must match", event: Event{key: KeyCtrlD, mod: ModAlt}, want: -1},
+	}
+
+	for _, tt := range tests {
+		got := findAction(actions, &tt.event)
+		if (got == nil) != (tt.want < 0) || (got != nil && got != &actions[tt.want]) {
+			t.Errorf("%s: got action %v, want action %d", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestActionScreen(t *testing.T) {
+	t.Parallel()
+
+	items := &substringList{"git log", "git status", "ls"}
+	deleteAction := Action[string]{
+		Key: KeyCtrlD,
+		Confirm: func(item ListItem[string]) string {
+			return "Delete " + item.Raw + "?"
+		},
+		Run: func(item ListItem[string]) (ActionResult, error) {
+			l := *items
+			for i, s := range l {
+				if s == item.Raw {
+					*items = append(l[:i:i], l[i+1:]...)
+				}
+			}
+			return ActionResult{Refresh: true, Message: "Deleted " + item.Raw}, nil
+		},
+	}
+
+	vt := newVirtualTerminal(30, 6)
+	errc := vt.run(t, func() error {
+		_, err := runList[string](vt, items, ListOptions{}, nil, []Action[string]{deleteAction})
+		return err
+	})
+
+	vt.press(t, "\x1b[B\x04")
+	want := []string{">", "Delete git status? [y/n]", "git log", "git status", "ls", ""}
+	if diff := cmp.Diff(vt.lines(), want); diff != "" {
+		t.Errorf("Screen while confirming diff (-got, +want):\n%s", diff)
+	}
+
+	vt.press(t, "y")
+	want = []string{">", "Deleted git status", "git log", "ls", "", ""}
+	if diff := cmp.Diff(vt.lines(), want); diff != "" {
+		t.Errorf("Screen after deleting diff (-got, +want):\n%s", diff)
+	}
+
+	vt.press(t, "l")
+	want = []string{"> l", "git log", "ls", "", "", ""}
+	if diff := cmp.Diff(vt.lines(), want); diff != "" {
+		t.Errorf("Screen after the next key press diff (-got, +want):\n%s", diff)
+	}
+
+	vt.press(t, "\x03")
+	<-errc
+}
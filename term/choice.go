@@ -0,0 +1,64 @@
+package term
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rithvikp/speeddial/term/termui"
+)
+
+// ChoiceOption is a single option in a choice dialog, selected by pressing Key.
+type ChoiceOption struct {
+	Key   rune
+	Label string
+}
+
+// Choice implements an interactive dialog where the user picks one of several options by pressing
+// the corresponding key. The message and options are printed out to stderr and the index of the
+// chosen option is returned. Other keys are ignored. The dialog is cleared before the function
+// returns.
+func Choice(msg string, options []ChoiceOption) (int, error) {
+	t, stop, err := openTty()
+	if err != nil {
+		return 0, err
+	}
+	defer stop()
+	return choice(t, msg, options)
+}
+
+// choice implements Choice in the given terminal.
+func choice(t Terminal, msg string, options []ChoiceOption) (int, error) {
+	labels := make([]string, 0, len(options))
+	for _, o := range options {
+		labels = append(labels, fmt.Sprintf("[%c] %s", o.Key, o.Label))
+	}
+
+	builder := &termui.Builder{Terminal: t}
+	builder.SaveCursor()
+
+	builder.WriteString(msg + " " + strings.Join(labels, ", "))
+	fmt.Fprint(t, builder.Commit())
+
+	defer func() {
+		builder.ResetCursor().ClearToScreenEnd()
+		fmt.Fprint(t, builder.Commit())
+	}()
+
+	for {
+		e, err := t.GetKeyboardEvent()
+		if err != nil {
+			return 0, fmt.Errorf("unable to process user keystroke: %v", err)
+		}
+
+		switch e.key {
+		case KeyCtrlC, KeyEscape:
+			return 0, ErrUserQuit
+		case KeyChar:
+			for i, o := range options {
+				if o.Key == e.char {
+					return i, nil
+				}
+			}
+		}
+	}
+}
@@ -2,31 +2,30 @@ package term
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/rithvikp/speeddial/term/termui"
 )
 
-// Confirmation implements an interactive confirmation dialog. The corresponding message is printed
-// out to stderr, with true being returned if the user confirms, false if not. If clearAfterUse is
-// set, the confirmation dialog will be cleared before the function returns.
+// Confirmation implements an interactive confirmation dialog. The corresponding message, which
+// may span multiple lines, is printed out to stderr, with true being returned if the user
+// confirms, false if not. If clearAfterUse is set, the confirmation dialog will be cleared before
+// the function returns.
 func Confirmation(msg string, clearAfterUse bool) (bool, error) {
-	t, err := NewTty()
-	defer func() {
-		err := t.Stop()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Unable to restore the terminal interface: %v", err)
-		}
-	}()
+	t, stop, err := openTty()
 	if err != nil {
-		return false, fmt.Errorf("unable to initialize the terminal interface: %v", err)
+		return false, err
 	}
+	defer stop()
+	return confirmation(t, msg, clearAfterUse)
+}
 
-	builder := &termui.Builder{}
+// confirmation implements Confirmation in the given terminal.
+func confirmation(t Terminal, msg string, clearAfterUse bool) (bool, error) {
+	builder := &termui.Builder{Terminal: t}
 	builder.SaveCursor()
 
-	builder.WriteString(msg + " [y/n]")
-	fmt.Fprint(os.Stderr, builder.Commit())
+	builder.WriteStringAndReformat(msg + " [y/n]")
+	fmt.Fprint(t, builder.Commit())
 
 	e, err := t.GetKeyboardEvent()
 	if err != nil {
@@ -34,7 +33,7 @@ func Confirmation(msg string, clearAfterUse bool) (bool, error) {
 	}
 
 	builder.ResetCursor().ClearToScreenEnd()
-	fmt.Fprint(os.Stderr, builder.Commit())
+	fmt.Fprint(t, builder.Commit())
 
 	if e.key == KeyChar && e.char == 'y' {
 		return true, nil
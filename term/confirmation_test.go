@@ -0,0 +1,52 @@
+package term
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfirmationScreen(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		msg  string
+		keys string
+		want bool
+	}{
+		{msg: "confirmed", keys: "y", want: true},
+		{msg: "declined", keys: "n", want: false},
+		{msg: "other keys", keys: "\r", want: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			t.Parallel()
+
+			vt := newVirtualTerminal(20, 4)
+			var got bool
+			errc := vt.run(t, func() error {
+				var err error
+				got, err = confirmation(vt, "Delete these\ncommands?", true)
+				return err
+			})
+
+			want := []string{"Delete these", "commands? [y/n]", "", ""}
+			if diff := cmp.Diff(vt.lines(), want); diff != "" {
+				t.Errorf("Screen diff (-got, +want):\n%s", diff)
+			}
+
+			vt.press(t, tt.keys)
+			if err := <-errc; err != nil {
+				t.Fatalf("Unable to confirm: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Got confirmation %t, want %t", got, tt.want)
+			}
+			if diff := cmp.Diff(vt.lines(), make([]string, 4)); diff != "" {
+				t.Errorf("Screen after confirming diff (-got, +want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package term
+
+import (
+	"fmt"
+
+	"github.com/pterm/pterm"
+	"github.com/rithvikp/speeddial/term/termui"
+)
+
+// FormField is a single input in a form. Value is the initial content of the field and History
+// contains previous values (most recent first) that the user can cycle through.
+type FormField struct {
+	Label   string
+	Value   string
+	History []string
+}
+
+// Form implements an interactive inline form, printing it out to stderr. Fields are filled in one
+// at a time and edited like the query of a List: "enter" or "tab" moves on to the next field and
+// the up and down arrow keys cycle through the field's history. The final value of every field is returned in the same order as the fields.
+func Form(title string, fields []FormField) ([]string, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	t, stop, err := openTty()
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+	return form(t, title, fields)
+}
+
+// form implements Form in the given terminal.
+func form(t Terminal, title string, fields []FormField) ([]string, error) {
+	values := make([]string, len(fields))
+	for i, f := range fields {
+		values[i] = f.Value
+	}
+
+	active := 0
+	editor := newLineEditor(values[active])
+	// The position in the active field's history, with -1 representing the initial value.
+	historyPos := -1
+
+	builder := &termui.Builder{Terminal: t}
+	builder.SaveCursor()
+
+	clear := func() {
+		builder.ResetCursor().ClearToScreenEnd()
+		fmt.Fprint(t, builder.Commit())
+	}
+	// show writes the fields up to and including the active one, with the active field's text cut
+	// off at the given rune.
+	show := func(end int) {
+		builder.ResetCursor().WriteString(pterm.Bold.Sprint(title)).ClearToLineEnd()
+		for i := 0; i <= active; i++ {
+			value := values[i]
+			if i == active {
+				value = string(editor.text[:end])
+			}
+			builder.NextLine().WriteString(fmt.Sprintf("  %s: %s", pterm.Cyan(fields[i].Label), value))
+		}
+	}
+
+	for {
+		values[active] = editor.String()
+
+		// Only the fields up to and including the active one are shown. They are then written
+		// again up to the cursor, which leaves the cursor in place even if the field has wrapped.
+		show(len(editor.text))
+		builder.ClearToScreenEnd()
+		show(editor.cursor)
+		fmt.Fprint(t, builder.Commit())
+
+		e, err := t.GetKeyboardEvent()
+		if err != nil {
+			clear()
+			return nil, fmt.Errorf("unable to process user keystroke: %v", err)
+		}
+
+		// Pastes and editing keys are handled by the editor, which ignores alt-chords that are not
+		// editing keys rather than typing them into the field
+		if handled, _ := editor.handle(e); handled {
+			continue
+		}
+
+		switch e.key {
+		case KeyUp:
+			if historyPos < len(fields[active].History)-1 {
+				historyPos++
+				editor = newLineEditor(fields[active].History[historyPos])
+			}
+
+		case KeyDown:
+			if historyPos > 0 {
+				historyPos--
+				editor = newLineEditor(fields[active].History[historyPos])
+			} else if historyPos == 0 {
+				historyPos--
+				editor = newLineEditor(fields[active].Value)
+			}
+
+		case KeyEnter, KeyTab:
+			if active == len(fields)-1 {
+				clear()
+				return values, nil
+			}
+			active++
+			editor = newLineEditor(values[active])
+			historyPos = -1
+
+		case KeyCtrlC, KeyEscape:
+			clear()
+			return nil, ErrUserQuit
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package term
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormScreen(t *testing.T) {
+	t.Parallel()
+
+	vt := newVirtualTerminal(30, 4)
+	var got []string
+	errc := vt.run(t, func() error {
+		var err error
+		got, err = form(vt, "Fill in:", []FormField{
+			{Label: "pod"},
+			{Label: "ns", Value: "default", History: []string{"kube-system"}},
+		})
+		return err
+	})
+
+	steps := []struct {
+		msg        string
+		keys       string
+		want       []string
+		wantCursor [2]int
+	}{
+		{
+			msg:        "paste",
+			keys:       pasteStart + "web-1" + pasteEnd,
+			want:       []string{"Fill in:", "  pod: web-1", "", ""},
+			wantCursor: [2]int{1, 12},
+		},
+		{
+			msg:        "alt-chords are not typed",
+			keys:       "\x1bx",
+			want:       []string{"Fill in:", "  pod: web-1", "", ""},
+			wantCursor: [2]int{1, 12},
+		},
+		{
+			msg:        "editing keys",
+			keys:       "\x1b[D\x1b[D\x7fa",
+			want:       []string{"Fill in:", "  pod: wea-1", "", ""},
+			wantCursor: [2]int{1, 10},
+		},
+		{
+			msg:        "next field",
+			keys:       "\r",
+			want:       []string{"Fill in:", "  pod: wea-1", "  ns: default", ""},
+			wantCursor: [2]int{2, 13},
+		},
+		{
+			msg:        "history",
+			keys:       "\x1b[A",
+			want:       []string{"Fill in:", "  pod: wea-1", "  ns: kube-system", ""},
+			wantCursor: [2]int{2, 17},
+		},
+	}
+
+	for _, st := range steps {
+		vt.press(t, st.keys)
+		if diff := cmp.Diff(vt.lines(), st.want); diff != "" {
+			t.Errorf("%s: screen diff (-got, +want):\n%s", st.msg, diff)
+		}
+		if row, col := vt.cursor(); row != st.wantCursor[0] || col != st.wantCursor[1] {
+			t.Errorf("%s: got the cursor at %d,%d, want %d,%d", st.msg, row, col, st.wantCursor[0], st.wantCursor[1])
+		}
+	}
+
+	vt.press(t, "\r")
+	if err := <-errc; err != nil {
+		t.Fatalf("Unable to fill in the form: %v", err)
+	}
+	if diff := cmp.Diff(got, []string{"wea-1", "kube-system"}); diff != "" {
+		t.Errorf("Values diff (-got, +want):\n%s", diff)
+	}
+	if diff := cmp.Diff(vt.lines(), make([]string, 4)); diff != "" {
+		t.Errorf("Screen after submitting diff (-got, +want):\n%s", diff)
+	}
+}
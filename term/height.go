@@ -0,0 +1,49 @@
+package term
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// minRows is the number of rows that a list with no height is given at least, as in fzf's
+// --min-height.
+const minRows = 10
+
+// Height is the maximum height of a list, either as a number of rows or as a percentage of the
+// terminal's height. The zero value fits the list in the rows below the cursor, unless there are
+// fewer than minRows.
+type Height struct {
+	Value   int
+	Percent bool
+}
+
+// ParseHeight parses a height such as "15" (rows) or "40%" (of the terminal's height), as in fzf.
+func ParseHeight(s string) (Height, error) {
+	h := Height{}
+	text := strings.TrimSpace(s)
+	if strings.HasSuffix(text, "%") {
+		h.Percent = true
+		text = strings.TrimSuffix(text, "%")
+	}
+
+	v, err := strconv.Atoi(text)
+	if err != nil || v <= 0 || (h.Percent && v > 100) {
+		return Height{}, fmt.Errorf("invalid height %q: expected a positive number of rows or a percentage", s)
+	}
+	h.Value = v
+	return h, nil
+}
+
+// rows returns the number of rows that the height allows in a terminal with the given height, with
+// the given number of rows available below the cursor. It is at least one and at most the height
+// of the terminal, which is scrolled if there are not enough rows below the cursor.
+func (h Height) rows(termHeight, available int) int {
+	rows := max(available, min(minRows, termHeight))
+	if h.Percent {
+		rows = termHeight * h.Value / 100
+	} else if h.Value > 0 {
+		rows = h.Value
+	}
+	return max(min(rows, termHeight), 1)
+}
@@ -0,0 +1,60 @@
+package term
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseHeight(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Height
+		wantErr bool
+	}{
+		{s: "15", want: Height{Value: 15}},
+		{s: " 40% ", want: Height{Value: 40, Percent: true}},
+		{s: "100%", want: Height{Value: 100, Percent: true}},
+		{s: "0", wantErr: true},
+		{s: "-5", wantErr: true},
+		{s: "101%", wantErr: true},
+		{s: "%", wantErr: true},
+		{s: "ten", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseHeight(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseHeight(%q) error: %v, want error: %t", tt.s, err, tt.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(got, tt.want); diff != "" {
+			t.Errorf("ParseHeight(%q) diff (-got, +want):\n%s", tt.s, diff)
+		}
+	}
+}
+
+func TestHeightRows(t *testing.T) {
+	tests := []struct {
+		h          Height
+		termHeight int
+		available  int
+		want       int
+	}{
+		{h: Height{}, termHeight: 24, available: 24, want: 24},
+		{h: Height{}, termHeight: 24, available: 15, want: 15},
+		{h: Height{}, termHeight: 24, available: 3, want: minRows},
+		{h: Height{}, termHeight: 6, available: 3, want: 6},
+		{h: Height{Value: 15}, termHeight: 24, available: 24, want: 15},
+		{h: Height{Value: 15}, termHeight: 24, available: 1, want: 15},
+		{h: Height{Value: 50}, termHeight: 24, available: 24, want: 24},
+		{h: Height{Value: 40, Percent: true}, termHeight: 50, available: 50, want: 20},
+		{h: Height{Value: 1, Percent: true}, termHeight: 50, available: 50, want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := tt.h.rows(tt.termHeight, tt.available); got != tt.want {
+			t.Errorf("%+v.rows(%d, %d) = %d, want %d", tt.h, tt.termHeight, tt.available, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package term
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// lineEditor is a single-line text input with a cursor. It supports the common emacs-style
+// editing keys that shells provide.
+type lineEditor struct {
+	text []rune
+	// The cursor is the index of the rune that it is in front of, so it ranges from 0 to
+	// len(text) inclusive.
+	cursor int
+}
+
+func newLineEditor(text string) *lineEditor {
+	l := &lineEditor{text: []rune(text)}
+	l.cursor = len(l.text)
+	return l
+}
+
+func (l *lineEditor) String() string {
+	return string(l.text)
+}
+
+// cursorColumn returns the column of the cursor relative to the start of the text, taking wide
+// characters into account.
+func (l *lineEditor) cursorColumn() int {
+	return runewidth.StringWidth(string(l.text[:l.cursor]))
+}
+
+// handle applies the keyboard event to the text. It returns whether the event was an editing
+// key (and so was consumed) and whether the text changed as a result.
+func (l *lineEditor) handle(e *Event) (handled, changed bool) {
+	before := l.String()
+	alt := e.mod&ModAlt != 0
+	word := e.mod&(ModAlt|ModCtrl) != 0
+
+	switch {
+	case e.key == KeyChar && !alt:
+		l.insert([]rune{e.char})
+	case e.key == KeyPaste:
+		l.insert(sanitizePaste(e.text))
+
+	case e.key == KeyLeft && word, e.key == KeyChar && alt && e.char == 'b':
+		l.cursor = l.wordStart(isWordRune)
+	case e.key == KeyRight && word, e.key == KeyChar && alt && e.char == 'f':
+		l.cursor = l.wordEnd(isWordRune)
+	case e.key == KeyLeft, e.key == KeyCtrlB:
+		l.cursor = max(l.cursor-1, 0)
+	case e.key == KeyRight, e.key == KeyCtrlF:
+		l.cursor = min(l.cursor+1, len(l.text))
+	case e.key == KeyHome, e.key == KeyCtrlA:
+		l.cursor = 0
+	case e.key == KeyEnd, e.key == KeyCtrlE:
+		l.cursor = len(l.text)
+
+	case (e.key == KeyDelete || e.key == KeyCtrlH) && alt:
+		l.deleteTo(l.wordStart(isWordRune))
+	case e.key == KeyDelete, e.key == KeyCtrlH:
+		l.deleteTo(max(l.cursor-1, 0))
+	case e.key == KeyCtrlW:
+		l.deleteTo(l.wordStart(func(r rune) bool { return !unicode.IsSpace(r) }))
+	case e.key == KeyCtrlU:
+		l.deleteTo(0)
+	case e.key == KeyChar && alt && e.char == 'd':
+		l.deleteTo(l.wordEnd(isWordRune))
+	case e.key == KeyForwardDelete:
+		l.deleteTo(min(l.cursor+1, len(l.text)))
+	case e.key == KeyCtrlK:
+		l.deleteTo(len(l.text))
+
+	default:
+		return false, false
+	}
+
+	return true, l.String() != before
+}
+
+func (l *lineEditor) insert(runes []rune) {
+	text := make([]rune, 0, len(l.text)+len(runes))
+	text = append(text, l.text[:l.cursor]...)
+	text = append(text, runes...)
+	l.text = append(text, l.text[l.cursor:]...)
+	l.cursor += len(runes)
+}
+
+// deleteTo deletes the text between the cursor and the given position, which can be on either
+// side of the cursor.
+func (l *lineEditor) deleteTo(pos int) {
+	start, end := min(pos, l.cursor), max(pos, l.cursor)
+	l.text = append(l.text[:start], l.text[end:]...)
+	l.cursor = start
+}
+
+// wordStart returns the start of the word before the cursor, where words are made up of runes
+// for which inWord returns true.
+func (l *lineEditor) wordStart(inWord func(rune) bool) int {
+	i := l.cursor
+	for i > 0 && !inWord(l.text[i-1]) {
+		i--
+	}
+	for i > 0 && inWord(l.text[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordEnd returns the end of the word after the cursor, where words are made up of runes for
+// which inWord returns true.
+func (l *lineEditor) wordEnd(inWord func(rune) bool) int {
+	i := l.cursor
+	for i < len(l.text) && !inWord(l.text[i]) {
+		i++
+	}
+	for i < len(l.text) && inWord(l.text[i]) {
+		i++
+	}
+	return i
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// sanitizePaste converts pasted text into a single line, replacing new lines and tabs with spaces
+// and dropping any other control characters.
+func sanitizePaste(text string) []rune {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var runes []rune
+	for _, r := range text {
+		if r == '\n' || r == '\r' || r == '\t' {
+			runes = append(runes, ' ')
+		} else if !unicode.IsControl(r) {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
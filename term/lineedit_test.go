@@ -0,0 +1,121 @@
+package term
+
+import "testing"
+
+func TestLineEditor(t *testing.T) {
+	chars := func(s string) []Event {
+		var events []Event
+		for _, r := range s {
+			events = append(events, Event{key: KeyChar, char: r})
+		}
+		return events
+	}
+
+	tests := []struct {
+		msg        string
+		initial    string
+		events     []Event
+		wantText   string
+		wantCursor int
+	}{
+		{
+			msg:        "Typing appends at the cursor",
+			initial:    "gt",
+			events:     append([]Event{{key: KeyLeft}}, chars("i")...),
+			wantText:   "git",
+			wantCursor: 2,
+		},
+		{
+			msg:        "Backspace removes a whole rune",
+			initial:    "café",
+			events:     []Event{{key: KeyDelete}},
+			wantText:   "caf",
+			wantCursor: 3,
+		},
+		{
+			msg:        "Home and end",
+			initial:    "log",
+			events:     append(append([]Event{{key: KeyHome}}, chars("git ")...), Event{key: KeyEnd}),
+			wantText:   "git log",
+			wantCursor: 7,
+		},
+		{
+			msg:        "Ctrl-W deletes a whitespace-delimited word",
+			initial:    "git log --graph  ",
+			events:     []Event{{key: KeyCtrlW}},
+			wantText:   "git log ",
+			wantCursor: 8,
+		},
+		{
+			msg:        "Alt-backspace deletes an alphanumeric word",
+			initial:    "git log --graph",
+			events:     []Event{{key: KeyDelete, mod: ModAlt}},
+			wantText:   "git log --",
+			wantCursor: 10,
+		},
+		{
+			msg:        "Ctrl-U deletes up to the cursor",
+			initial:    "git log",
+			events:     []Event{{key: KeyLeft, mod: ModCtrl}, {key: KeyCtrlU}},
+			wantText:   "log",
+			wantCursor: 0,
+		},
+		{
+			msg:        "Word motions",
+			initial:    "kubectl get pods",
+			events:     []Event{{key: KeyHome}, {key: KeyChar, char: 'f', mod: ModAlt}, {key: KeyRight, mod: ModCtrl}, {key: KeyChar, char: 'b', mod: ModAlt}},
+			wantText:   "kubectl get pods",
+			wantCursor: 8,
+		},
+		{
+			msg:        "Forward deletes",
+			initial:    "kubectl get pods",
+			events:     []Event{{key: KeyHome}, {key: KeyForwardDelete}, {key: KeyChar, char: 'd', mod: ModAlt}, {key: KeyEnd}, {key: KeyLeft}, {key: KeyCtrlK}},
+			wantText:   " get pod",
+			wantCursor: 8,
+		},
+		{
+			msg:        "Paste is inserted as a single line",
+			initial:    "echo ",
+			events:     []Event{{key: KeyPaste, text: "a\r\nb\tc\x1b"}},
+			wantText:   "echo a b c",
+			wantCursor: 10,
+		},
+		{
+			msg:        "Wide characters take up two columns",
+			initial:    "日本語",
+			events:     []Event{{key: KeyLeft}},
+			wantText:   "日本語",
+			wantCursor: 4,
+		},
+		{
+			msg:        "Cursor stays within the text",
+			initial:    "ab",
+			events:     []Event{{key: KeyRight}, {key: KeyHome}, {key: KeyLeft}, {key: KeyDelete}},
+			wantText:   "ab",
+			wantCursor: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		l := newLineEditor(tt.initial)
+		for _, e := range tt.events {
+			e := e
+			if handled, _ := l.handle(&e); !handled {
+				t.Errorf("%s: event %+v was not handled", tt.msg, e)
+			}
+		}
+
+		if l.String() != tt.wantText || l.cursorColumn() != tt.wantCursor {
+			t.Errorf("%s: got %q with the cursor at %d, want %q with the cursor at %d", tt.msg, l.String(), l.cursorColumn(), tt.wantText, tt.wantCursor)
+		}
+	}
+
+	l := newLineEditor("")
+	for _, e := range []Event{{key: KeyEnter}, {key: KeyUp}, {key: KeyChar, char: 'x', mod: ModAlt}} {
+		e := e
+		if handled, _ := l.handle(&e); handled {
+			t.Errorf("Event %+v should not be handled by the line editor", e)
+		}
+	}
+}
@@ -3,10 +3,12 @@ package term
 import (
 	"errors"
 	"fmt"
-	"os"
 	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/mattn/go-runewidth"
 	"github.com/pterm/pterm"
 	"github.com/rithvikp/speeddial/term/termui"
 	"golang.org/x/exp/constraints"
@@ -45,11 +47,38 @@ var (
 )
 
 // QueryableList abstracts a searchable corpus of data. The Search method will be repeatedly called as
-// the query changes.
+// the query changes. If the query is invalid, Search should return an error that wraps
+// ErrQueryableListInvalidQuery, whose message is shown to the user.
 type QueryableList[T any] interface {
 	Search(query string) ([]ListItem[T], error)
 }
 
+// Toggle is a setting of a list that the user can turn on and off while searching.
+type Toggle struct {
+	// Key is the character that flips the toggle when pressed with alt.
+	Key rune
+	// Label is shown after the query while the toggle is on.
+	Label string
+	// Enabled determines whether the toggle is on.
+	Enabled func() bool
+	// Flip turns the toggle on or off, after which the list is searched again.
+	Flip func()
+}
+
+// ToggleableList is implemented by lists with settings that can be toggled while searching.
+type ToggleableList interface {
+	Toggles() []Toggle
+}
+
+// ModalList is implemented by lists that can search in multiple modes, such as fuzzy and regex
+// search. The user can cycle through the modes while searching without losing their query.
+type ModalList interface {
+	// Mode returns the name of the current mode, which is shown in the prompt.
+	Mode() string
+	// NextMode switches to the next mode, after which the list is searched again.
+	NextMode()
+}
+
 func min[T constraints.Ordered](a, b T) T {
 	if a <= b {
 		return a
@@ -64,36 +93,143 @@ func max[T constraints.Ordered](a, b T) T {
 	return b
 }
 
+// Timing of the search spinner, which is shown after the query once a search has been running for a
+// little while.
+const (
+	searchSpinnerDelay = 100 * time.Millisecond
+	searchPollInterval = 50 * time.Millisecond
+)
+
+// resizePollInterval is how often the terminal is checked for resizes while waiting for a key press.
+const resizePollInterval = 100 * time.Millisecond
+
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// ListOptions configures the behavior of List and ListMulti.
+type ListOptions struct {
+	// MaxToDisplay is the maximum number of items that are shown at once. If it is zero, as many
+	// items are shown as fit within the height.
+	MaxToDisplay int
+	// Height limits the number of rows taken up by the list, including the query. The list never
+	// takes up more rows than the terminal has, so that it is always fully visible, and the
+	// terminal is scrolled up front if there are not enough rows below the cursor.
+	Height Height
+	// VimNavigation enables a normal mode, entered by pressing "escape", in which the list can be
+	// navigated with "j" and "k". The Vim bindings are currently limited to just list navigation.
+	VimNavigation bool
+	// Query is the initial query, which the user can then edit.
+	Query string
+}
+
 // List implements an interactive terminal list, printing the interface out to stderr and allowing
-// the user to navigate and choose an option.
+// the user to navigate and choose an option. If the payloads implement Previewer, a preview of the
+// selected item can be toggled with ctrl-p, and if the list implements ToggleableList, its toggles
+// can be flipped with alt and the toggle's key. If the list implements ModalList, ctrl-r cycles
+// through its modes. Searches run in the background and, if the list implements CancellableList,
+// searches for outdated queries are cancelled.
 //
-// The Vim bindings are currently limited to just list navigation.
-func List[Payload any](list QueryableList[Payload], maxToDisplay int, vimNavigation bool) (Payload, error) {
-	t, err := NewTty()
-	defer func() {
-		err := t.Stop()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Unable to restore the terminal interface: %v", err)
-		}
-	}()
-
+// Actions can be bound to keys to act on the selected item without leaving the list (see Action).
+func List[Payload any](list QueryableList[Payload], opts ListOptions, actions ...Action[Payload]) (Payload, error) {
 	var emptyPayload Payload
+	t, stop, err := openTty()
 	if err != nil {
-		return emptyPayload, fmt.Errorf("unable to initialize the terminal interface: %v", err)
+		return emptyPayload, err
 	}
+	defer stop()
 
-	query := ""
-	items, err := list.Search(query)
-	if err != nil && err != ErrQueryableListInvalidQuery {
-		return emptyPayload, fmt.Errorf("unable to handle search query: %v", err)
+	selected, err := runList[Payload](t, list, opts, nil, actions)
+	if err != nil {
+		return emptyPayload, err
 	}
+	return selected[0], nil
+}
+
+// ListMulti is like List but allows the user to mark multiple items with "tab" before pressing
+// "enter". "alt-a" marks every item that matches the current query and "alt-n" clears all marks.
+// The marked items are returned in the order in which they were marked. If no items are marked,
+// the item under the cursor is returned.
+func ListMulti[Payload comparable](list QueryableList[Payload], opts ListOptions) ([]Payload, error) {
+	t, stop, err := openTty()
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+	return runList[Payload](t, list, opts, newComparableMarks[Payload](), nil)
+}
+
+// marks tracks the items that have been marked in a multi-select list.
+type marks[T any] interface {
+	toggle(item T)
+	contains(item T) bool
+	values() []T
+	clear()
+}
+
+type comparableMarks[T comparable] struct {
+	order []T
+	set   map[T]bool
+}
+
+func newComparableMarks[T comparable]() *comparableMarks[T] {
+	return &comparableMarks[T]{set: make(map[T]bool)}
+}
+
+func (m *comparableMarks[T]) toggle(item T) {
+	if !m.set[item] {
+		m.set[item] = true
+		m.order = append(m.order, item)
+		return
+	}
+
+	delete(m.set, item)
+	for i, v := range m.order {
+		if v == item {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (m *comparableMarks[T]) contains(item T) bool {
+	return m.set[item]
+}
+
+func (m *comparableMarks[T]) values() []T {
+	return m.order
+}
+
+func (m *comparableMarks[T]) clear() {
+	m.order = nil
+	m.set = make(map[T]bool)
+}
 
+// runList implements List and ListMulti in the given terminal. Multi-select is enabled if marked
+// is non-nil.
+func runList[Payload any](t Terminal, list QueryableList[Payload], opts ListOptions, marked marks[Payload], actions []Action[Payload]) ([]Payload, error) {
+	// The number of items that fit on the screen, which is updated whenever the list is shown
+	maxToDisplay := 1
+	editor := newLineEditor(opts.Query)
+
+	// Searches run in the background so that keystrokes are handled while they are running
+	search := newAsyncSearch(list)
+	defer search.close()
+
+	var items []ListItem[Payload]
+	// The error for the current query if it is invalid.
+	var queryErr error
 	displayOffset := 0
 	selected := 0
 	normalMode := false
-	invalidQuery := false
+	showPreview := false
+
+	// The action that is waiting for the user to confirm it, and the item that it applies to
+	var pending *Action[Payload]
+	var pendingItem ListItem[Payload]
+	// A message from the last action, which is shown until the next key press
+	var status string
+	statusIsErr := false
 
-	builder := &termui.Builder{}
+	builder := &termui.Builder{Terminal: t}
 	builder.SaveCursor()
 
 	listNavDown := func() {
@@ -112,115 +248,348 @@ func List[Payload any](list QueryableList[Payload], maxToDisplay int, vimNavigat
 			selected--
 		}
 	}
+	applyResult := func(r searchResult[Payload]) error {
+		queryErr = nil
+		if errors.Is(r.err, ErrQueryableListInvalidQuery) {
+			queryErr = r.err
+			return nil
+		} else if r.err != nil {
+			// Wipe any content added by this function
+			builder.ResetCursor().ClearToScreenEnd()
+			fmt.Fprint(t, builder.Commit())
+			return fmt.Errorf("unable to handle search query: %v", r.err)
+		}
+
+		items = r.items
+		selected = max(min(selected, len(items)-1), 0)
+		displayOffset = max(min(displayOffset, len(items)-maxToDisplay), 0)
+		return nil
+	}
+
+	// finishSearch waits for the current search, if any, so that the selection reflects the query
+	// that the user can see.
+	finishSearch := func() error {
+		if !search.running {
+			return nil
+		}
+		return applyResult(search.wait())
+	}
+
+	// runAction runs an action on an item. An error is only returned if the list can not continue.
+	runAction := func(a *Action[Payload], item ListItem[Payload]) error {
+		// Actions may modify the list, so no searches can be running
+		search.close()
+
+		if a.Suspend {
+			builder.ResetCursor().ClearToScreenEnd()
+			fmt.Fprint(t, builder.Commit())
+			if err := t.Stop(); err != nil {
+				return fmt.Errorf("unable to restore the terminal interface: %v", err)
+			}
+		}
+
+		res, actionErr := a.Run(item)
+
+		if a.Suspend {
+			if err := t.Start(); err != nil {
+				return fmt.Errorf("unable to initialize the terminal interface: %v", err)
+			}
+			builder.SaveCursor()
+		}
+
+		status, statusIsErr = res.Message, false
+		if actionErr != nil {
+			status, statusIsErr = actionErr.Error(), true
+		}
+
+		if res.Close {
+			builder.ResetCursor().ClearToScreenEnd()
+			fmt.Fprint(t, builder.Commit())
+			return ErrClosedByAction
+		}
+		if res.Refresh {
+			search.start(editor.String())
+		}
+		return nil
+	}
+
+	// There is nothing to show until the initial search finishes, so it is waited for
+	search.start(editor.String())
+	if err := applyResult(search.wait()); err != nil {
+		return nil, err
+	}
 
 	// Every iteration, first update the interface (print a single string with all the content
 	// and relevant escape codes in order to have a smooth UI). Then, wait for a keystroke,
 	// handle it appropriately, and repeat the entire process.
 	for {
-		builder.MoveCursor(termui.CursorLineStart())
+		width, height, err := t.Size()
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine the size of the terminal: %v", err)
+		}
+		// The list is shown below the query, making room for it first if there are not enough
+		// rows below the cursor
+		rows := opts.Height.rows(height, builder.RowsBelowSave())
+		builder.ResetCursor().ReserveRows(rows)
 
 		// Print the updated interface
-		formattedQuery := query
-		if invalidQuery {
+		formattedQuery := editor.String()
+		if queryErr != nil {
 			formattedQuery = pterm.BgRed.Sprint(formattedQuery)
 		}
-		builder.WriteString(fmt.Sprint("> ", formattedQuery)).ClearToLineEnd().NextLine()
+		prompt := "> "
+		if ml, ok := list.(ModalList); ok {
+			prompt = ml.Mode() + prompt
+		}
+		builder.WriteString(prompt + formattedQuery)
+		// The query line, as well as any messages below it, are shown before the items
+		chrome := 1
+		if elapsed := search.elapsed(); elapsed > searchSpinnerDelay {
+			frame := spinnerFrames[int(elapsed/searchPollInterval)%len(spinnerFrames)]
+			builder.WriteString(" " + pterm.Gray(string(frame)))
+		}
+		if marked != nil && len(marked.values()) > 0 {
+			builder.WriteString(" " + pterm.Green(fmt.Sprintf("[%d marked]", len(marked.values()))))
+		}
+		for _, tg := range toggles(list) {
+			if tg.Enabled() {
+				builder.WriteString(" " + pterm.Gray("["+tg.Label+"]"))
+			}
+		}
+		builder.ClearToLineEnd().NextLine()
+		if queryErr != nil {
+			builder.WriteString(truncate(pterm.Red(queryErr.Error()), width)).ClearToLineEnd().NextLine()
+			chrome++
+		}
+		if pending != nil {
+			builder.WriteString(truncate(pterm.Yellow(pending.Confirm(pendingItem)+" [y/n]"), width)).ClearToLineEnd().NextLine()
+			chrome++
+		} else if statusIsErr {
+			builder.WriteString(truncate(pterm.Red(status), width)).ClearToLineEnd().NextLine()
+			chrome++
+		} else if status != "" {
+			builder.WriteString(truncate(pterm.Gray(status), width)).ClearToLineEnd().NextLine()
+			chrome++
+		}
+
+		// The preview is cut short if needed so that at least one item is shown
+		var preview []string
+		if showPreview && selected < len(items) {
+			if p, ok := any(items[selected].Raw).(Previewer); ok {
+				preview = strings.Split(renderPreview(p.Preview(), width), "\n")
+				preview = preview[:max(min(len(preview), rows-chrome-1), 0)]
+			}
+		}
+
+		// Show as many items as fit in the remaining rows, keeping the selected item in view
+		maxToDisplay = max(rows-chrome-len(preview), 1)
+		if opts.MaxToDisplay > 0 {
+			maxToDisplay = min(maxToDisplay, opts.MaxToDisplay)
+		}
+		if selected-displayOffset >= maxToDisplay {
+			displayOffset = selected - maxToDisplay + 1
+		}
+		displayOffset = max(min(displayOffset, len(items)-maxToDisplay), 0)
 
-		tbl, err := generateList(t, items, displayOffset, maxToDisplay, selected)
+		tbl, err := generateList(t, items, displayOffset, maxToDisplay, selected, marked)
 		if err != nil {
-			return emptyPayload, err
+			return nil, err
 		}
 
 		// Write the table and then wipe the rest of the screen downwards to remove old,
-		// trailing text
-		builder.WriteStringAndReformat(tbl).ClearToScreenEnd()
+		// trailing text. Lines are cut off at the width of the terminal as wrapped lines would
+		// push the list past the rows that it has.
+		builder.WriteStringAndReformat(truncate(tbl, width))
+		if len(preview) > 0 {
+			builder.NextLine().WriteStringAndReformat(strings.Join(preview, "\n"))
+		}
+		builder.ClearToScreenEnd()
 
-		// Move the cursor back to the end of the query
-		builder.ResetCursor().MoveCursor(termui.CursorRight(len("> ") + len(query)))
+		// Move the cursor back to its position in the query
+		builder.ResetCursor().MoveCursor(termui.CursorRight(len(prompt) + editor.cursorColumn()))
 
-		fmt.Fprint(os.Stderr, builder.Commit())
+		fmt.Fprint(t, builder.Commit())
 
-		// Handle keyboard events accordingly
-		e, err := t.GetKeyboardEvent()
-		if err != nil {
-			return emptyPayload, fmt.Errorf("unable to process user keystroke: %v", err)
+		// Handle keyboard events accordingly. While a search is running, the interface is also
+		// periodically updated so that its results are shown as soon as they are ready. Otherwise,
+		// it is only updated after a key press or when the terminal is resized.
+		var e *Event
+		resized := false
+		for e == nil && !resized {
+			if search.running {
+				e, err = t.PollKeyboardEvent(searchPollInterval)
+			} else {
+				e, err = t.PollKeyboardEvent(resizePollInterval)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unable to process user keystroke: %v", err)
+			}
+			resized = t.Resized()
+			if search.running {
+				break
+			}
 		}
 
-		rerunQuery := false
+		if r, ok := search.poll(); ok {
+			if err := applyResult(r); err != nil {
+				return nil, err
+			}
+		}
+		if resized {
+			// The old interface may have been rewrapped by the terminal, so it is wiped from the
+			// query downwards and shown again from scratch, below the query's new position
+			builder.ResetCursor().ClearToScreenEnd()
+			fmt.Fprint(t, builder.Commit())
+			builder.SaveCursor()
+		}
+		if e == nil {
+			continue
+		}
+		status, statusIsErr = "", false
+
+		// A pending confirmation consumes the next key press, with anything other than "y"
+		// cancelling the action
+		if pending != nil {
+			a, item := pending, pendingItem
+			pending = nil
+			if e.key == KeyChar && e.char == 'y' && e.mod == 0 {
+				if err := runAction(a, item); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
 
-		switch e.key {
-		case KeyChar:
-			if !normalMode {
-				query += string(e.char)
-				rerunQuery = true
-				break
+		if a := findAction(actions, e); a != nil {
+			if err := finishSearch(); err != nil {
+				return nil, err
+			}
+			if selected >= len(items) {
+				continue
 			}
 
-			if e.char == 'j' {
-				listNavDown()
-			} else if e.char == 'k' {
-				listNavUp()
-			} else if e.char == 'i' || e.char == 'a' {
-				normalMode = false
+			if a.Confirm != nil {
+				pending, pendingItem = a, items[selected]
+			} else if err := runAction(a, items[selected]); err != nil {
+				return nil, err
 			}
+			continue
+		}
+
+		// Editing keys are handled first, so they take precedence over the list's own bindings
+		handled, rerunQuery := false, false
+		if !normalMode {
+			handled, rerunQuery = editor.handle(e)
+		}
 
-		case KeyEnter:
-			if selected < 0 || selected >= len(items) {
-				return emptyPayload, errors.New("unable to select an item")
+		if !handled && e.key == KeyChar && e.mod&ModAlt != 0 && marked != nil {
+			switch e.char {
+			case 'a':
+				for _, item := range items {
+					if !marked.contains(item.Raw) {
+						marked.toggle(item.Raw)
+					}
+				}
+				handled = true
+			case 'n':
+				marked.clear()
+				handled = true
 			}
-			// Wipe any content added by this function
-			builder.ResetCursor().ClearToScreenEnd()
-			fmt.Fprint(os.Stderr, builder.Commit())
+		}
+		if !handled && e.key == KeyChar && e.mod&ModAlt != 0 {
+			for _, tg := range toggles(list) {
+				if tg.Key == e.char {
+					tg.Flip()
+					handled, rerunQuery = true, true
+				}
+			}
+		}
 
-			return items[selected].Raw, nil
+		if !handled {
+			switch e.key {
+			case KeyChar:
+				if !normalMode {
+					break
+				}
+
+				if e.char == 'j' {
+					listNavDown()
+				} else if e.char == 'k' {
+					listNavUp()
+				} else if e.char == 'i' || e.char == 'a' {
+					normalMode = false
+				}
+
+			case KeyEnter:
+				if err := finishSearch(); err != nil {
+					return nil, err
+				}
+				// Marked items are returned even if none of them match the current query
+				hasMarks := marked != nil && len(marked.values()) > 0
+				if !hasMarks && (selected < 0 || selected >= len(items)) {
+					return nil, errors.New("unable to select an item")
+				}
+				// Wipe any content added by this function
+				builder.ResetCursor().ClearToScreenEnd()
+				fmt.Fprint(t, builder.Commit())
 
-		case KeyCtrlC:
-			// Wipe any content added by this function
-			builder.ResetCursor().ClearToScreenEnd()
-			fmt.Fprint(os.Stderr, builder.Commit())
+				if hasMarks {
+					return marked.values(), nil
+				}
+				return []Payload{items[selected].Raw}, nil
 
-			return emptyPayload, ErrUserQuit
+			case KeyTab:
+				if marked != nil && selected >= 0 && selected < len(items) {
+					marked.toggle(items[selected].Raw)
+					listNavDown()
+				}
 
-		case KeyDelete:
-			if len(query) > 0 {
-				query = query[:len(query)-1]
-				rerunQuery = true
-			}
+			case KeyCtrlP:
+				showPreview = !showPreview
 
-		case KeyUp:
-			listNavUp()
+			case KeyCtrlR:
+				if ml, ok := list.(ModalList); ok {
+					ml.NextMode()
+					rerunQuery = true
+				}
 
-		case KeyDown:
-			listNavDown()
+			case KeyCtrlC:
+				// Wipe any content added by this function
+				builder.ResetCursor().ClearToScreenEnd()
+				fmt.Fprint(t, builder.Commit())
 
-		case KeyEscape:
-			if !vimNavigation {
-				return emptyPayload, ErrUserQuit
-			}
+				return nil, ErrUserQuit
+
+			case KeyUp:
+				listNavUp()
+
+			case KeyDown:
+				listNavDown()
 
-			normalMode = true
+			case KeyEscape:
+				if !opts.VimNavigation {
+					return nil, ErrUserQuit
+				}
+
+				normalMode = true
+			}
 		}
 
 		if rerunQuery {
-			invalidQuery = false
-			newItems, err := list.Search(query)
-			if err == ErrQueryableListInvalidQuery {
-				invalidQuery = true
-			} else if err != nil {
-				// Wipe any content added by this function
-				builder.ResetCursor().ClearToScreenEnd()
-				fmt.Fprint(os.Stderr, builder.Commit())
-				return emptyPayload, fmt.Errorf("unable to handle search query: %v", err)
-			} else {
-				items = newItems
-				selected = max(min(selected, len(items)-1), 0)
-				displayOffset = max(min(displayOffset, len(items)-1-maxToDisplay), 0)
-			}
+			search.start(editor.String())
 		}
 	}
 }
 
-func generateList[T any](t *Tty, items []ListItem[T], displayOffset, maxToDisplay, selected int) (string, error) {
+// toggles returns the list's toggles, if it has any.
+func toggles(list any) []Toggle {
+	if tl, ok := list.(ToggleableList); ok {
+		return tl.Toggles()
+	}
+	return nil
+}
+
+func generateList[T any](t Terminal, items []ListItem[T], displayOffset, maxToDisplay, selected int, marked marks[T]) (string, error) {
 	if displayOffset < 0 || maxToDisplay < 0 {
 		return "", fmt.Errorf("invalid display offset %d and/or range %d", displayOffset, maxToDisplay)
 	} else if len(items) == 0 {
@@ -238,6 +607,14 @@ func generateList[T any](t *Tty, items []ListItem[T], displayOffset, maxToDispla
 		item := items[i]
 
 		var formatted []string
+		if marked != nil {
+			mark := " "
+			if marked.contains(item.Raw) {
+				mark = pterm.Green("*")
+			}
+			formatted = append(formatted, mark)
+		}
+
 		for _, elem := range item.DisplayFields {
 
 			// Highlight matching text
@@ -290,3 +667,49 @@ func formatContent(origContent string, chunks []FormattedChunk, f func(string) s
 
 	return strings.Join(fmtChunks, ""), nil
 }
+
+// truncate cuts each line of the text off at the given width so that it does not wrap. Escape
+// sequences (e.g. colors) take up no space, and the formatting of lines that are cut off is reset.
+func truncate(s string, width int) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if runewidth.StringWidth(pterm.RemoveColorFromString(line)) <= width {
+			continue
+		}
+
+		var b strings.Builder
+		col := 0
+		for j := 0; j < len(line); {
+			if line[j] == byte(KeyEscape) {
+				end := escapeEnd(line, j)
+				b.WriteString(line[j:end])
+				j = end
+				continue
+			}
+
+			r, n := utf8.DecodeRuneInString(line[j:])
+			w := runewidth.RuneWidth(r)
+			if col+w > width {
+				break
+			}
+			b.WriteString(line[j : j+n])
+			col += w
+			j += n
+		}
+		lines[i] = b.String() + "\033[0m"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapeEnd returns the end of the escape sequence that starts at the given offset.
+func escapeEnd(s string, start int) int {
+	if start+1 >= len(s) || s[start+1] != '[' {
+		return min(start+2, len(s))
+	}
+	for i := start + 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(s)
+}
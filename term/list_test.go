@@ -0,0 +1,386 @@
+package term
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestComparableMarks(t *testing.T) {
+	m := newComparableMarks[string]()
+	for _, item := range []string{"c", "a", "b", "a", "d"} {
+		m.toggle(item)
+	}
+
+	if diff := cmp.Diff(m.values(), []string{"c", "b", "d"}); diff != "" {
+		t.Errorf("Marked values diff (-got, +want):\n%s", diff)
+	}
+	if m.contains("a") || !m.contains("b") {
+		t.Errorf("Got marks %v, want \"b\" but not \"a\" to be marked", m.values())
+	}
+
+	m.clear()
+	if len(m.values()) != 0 || m.contains("b") {
+		t.Errorf("Got marks %v after clearing, want none", m.values())
+	}
+	m.toggle("a")
+	if diff := cmp.Diff(m.values(), []string{"a"}); diff != "" {
+		t.Errorf("Marked values after clearing diff (-got, +want):\n%s", diff)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		msg   string
+		s     string
+		width int
+		want  string
+	}{
+		{
+			msg:   "short lines",
+			s:     "git log\nls",
+			width: 7,
+			want:  "git log\nls",
+		},
+		{
+			msg:   "long lines",
+			s:     "git log --oneline\nls",
+			width: 7,
+			want:  "git log\033[0m\nls",
+		},
+		{
+			msg:   "escape sequences take up no space",
+			s:     "\033[1mgit\033[0m log --oneline",
+			width: 5,
+			want:  "\033[1mgit\033[0m l\033[0m",
+		},
+		{
+			msg:   "wide characters",
+			s:     "日本語",
+			width: 5,
+			want:  "日本\033[0m",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.width); got != tt.want {
+			t.Errorf("%s: truncate(%q, %d) = %q, want %q", tt.msg, tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+// substringList is a list of strings that match queries that they contain.
+type substringList []string
+
+func (l substringList) Search(query string) ([]ListItem[string], error) {
+	var items []ListItem[string]
+	for _, s := range l {
+		if i := strings.Index(s, query); i >= 0 {
+			items = append(items, ListItem[string]{
+				DisplayFields: []FormattedContent{{Content: s, Highlights: []FormattedChunk{{Start: i, Length: len(query)}}}},
+				Raw:           s,
+			})
+		}
+	}
+	return items, nil
+}
+
+func TestListScreen(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+	vt := newVirtualTerminal(30, 8)
+	errc := vt.run(t, func() error {
+		var err error
+		got, err = runList[string](vt, substringList{"git log", "git status", "docker ps", "ls -la"}, ListOptions{MaxToDisplay: 10}, nil, nil)
+		return err
+	})
+
+	steps := []struct {
+		msg          string
+		keys         string
+		want         []string
+		wantSelected string
+		wantCursor   [2]int
+	}{
+		{
+			msg:          "initial list",
+			want:         []string{">", "git log", "git status", "docker ps", "ls -la", "", "", ""},
+			wantSelected: "git log",
+			wantCursor:   [2]int{0, 2},
+		},
+		{
+			msg:          "query",
+			keys:         "gi",
+			want:         []string{"> gi", "git log", "git status", "", "", "", "", ""},
+			wantSelected: "git log",
+			wantCursor:   [2]int{0, 4},
+		},
+		{
+			msg:          "navigation",
+			keys:         "\x1b[B\x1b[B",
+			want:         []string{"> gi", "git log", "git status", "", "", "", "", ""},
+			wantSelected: "git status",
+			wantCursor:   [2]int{0, 4},
+		},
+		{
+			msg:          "cursor movement",
+			keys:         "\x1b[D",
+			want:         []string{"> gi", "git log", "git status", "", "", "", "", ""},
+			wantSelected: "git status",
+			wantCursor:   [2]int{0, 3},
+		},
+		{
+			msg:        "no matches",
+			keys:       "x",
+			want:       []string{"> gxi", "", "", "", "", "", "", ""},
+			wantCursor: [2]int{0, 4},
+		},
+		{
+			msg:          "deleted text",
+			keys:         "\x7f",
+			want:         []string{"> gi", "git log", "git status", "", "", "", "", ""},
+			wantSelected: "git log",
+			wantCursor:   [2]int{0, 3},
+		},
+		{
+			msg:          "navigation after the matches change",
+			keys:         "\x1b[B",
+			want:         []string{"> gi", "git log", "git status", "", "", "", "", ""},
+			wantSelected: "git status",
+			wantCursor:   [2]int{0, 3},
+		},
+	}
+
+	for _, st := range steps {
+		vt.press(t, st.keys)
+		if diff := cmp.Diff(vt.lines(), st.want); diff != "" {
+			t.Errorf("%s: screen diff (-got, +want):\n%s", st.msg, diff)
+		}
+		if selected := vt.boldText(); selected != st.wantSelected {
+			t.Errorf("%s: got %q selected, want %q", st.msg, selected, st.wantSelected)
+		}
+		if row, col := vt.cursor(); row != st.wantCursor[0] || col != st.wantCursor[1] {
+			t.Errorf("%s: got the cursor at %d,%d, want %d,%d", st.msg, row, col, st.wantCursor[0], st.wantCursor[1])
+		}
+	}
+
+	vt.press(t, "\r")
+	if err := <-errc; err != nil {
+		t.Fatalf("Unable to select an item: %v", err)
+	}
+	if diff := cmp.Diff(got, []string{"git status"}); diff != "" {
+		t.Errorf("Selection diff (-got, +want):\n%s", diff)
+	}
+	if diff := cmp.Diff(vt.lines(), make([]string, 8)); diff != "" {
+		t.Errorf("Screen after selecting diff (-got, +want):\n%s", diff)
+	}
+}
+
+func TestListScreenAfterPrompt(t *testing.T) {
+	t.Parallel()
+
+	vt := newVirtualTerminal(20, 12)
+	vt.screen.Write([]byte("prev\r\n$ "))
+	errc := vt.run(t, func() error {
+		_, err := runList[string](vt, substringList{"git log", "git status"}, ListOptions{MaxToDisplay: 10}, nil, nil)
+		return err
+	})
+
+	vt.press(t, "status")
+	want := append([]string{"prev", "$ > status", "git status"}, make([]string, 9)...)
+	if diff := cmp.Diff(vt.lines(), want); diff != "" {
+		t.Errorf("Screen diff (-got, +want):\n%s", diff)
+	}
+	if row, col := vt.cursor(); row != 1 || col != 10 {
+		t.Errorf("Got the cursor at %d,%d, want 1,10", row, col)
+	}
+
+	vt.press(t, "\x03")
+	if err := <-errc; err != ErrUserQuit {
+		t.Fatalf("Got error %v after quitting, want %v", err, ErrUserQuit)
+	}
+	want = append([]string{"prev", "$"}, make([]string, 10)...)
+	if diff := cmp.Diff(vt.lines(), want); diff != "" {
+		t.Errorf("Screen after quitting diff (-got, +want):\n%s", diff)
+	}
+	if row, col := vt.cursor(); row != 1 || col != 2 {
+		t.Errorf("Got the cursor at %d,%d after quitting, want 1,2", row, col)
+	}
+}
+
+func TestListScreenHeight(t *testing.T) {
+	t.Parallel()
+
+	var items substringList
+	for i := 0; i < 10; i++ {
+		items = append(items, fmt.Sprintf("item %d", i))
+	}
+
+	t.Run("terminal height", func(t *testing.T) {
+		t.Parallel()
+
+		vt := newVirtualTerminal(20, 8)
+		errc := vt.run(t, func() error {
+			_, err := runList[string](vt, items, ListOptions{}, nil, nil)
+			return err
+		})
+
+		want := []string{">", "item 0", "item 1", "item 2", "item 3", "item 4", "item 5", "item 6"}
+		if diff := cmp.Diff(vt.lines(), want); diff != "" {
+			t.Errorf("Screen diff (-got, +want):\n%s", diff)
+		}
+
+		vt.resize(t, 20, 4)
+		want = []string{">", "item 0", "item 1", "item 2"}
+		if diff := cmp.Diff(vt.lines(), want); diff != "" {
+			t.Errorf("Screen after resizing diff (-got, +want):\n%s", diff)
+		}
+
+		vt.press(t, "\x1b[B\x1b[B\x1b[B\x1b[B")
+		want = []string{">", "item 2", "item 3", "item 4"}
+		if diff := cmp.Diff(vt.lines(), want); diff != "" {
+			t.Errorf("Screen after scrolling diff (-got, +want):\n%s", diff)
+		}
+		if selected := vt.boldText(); selected != "item 4" {
+			t.Errorf("Got %q selected, want \"item 4\"", selected)
+		}
+
+		vt.resize(t, 20, 6)
+		want = []string{">", "item 2", "item 3", "item 4", "item 5", "item 6"}
+		if diff := cmp.Diff(vt.lines(), want); diff != "" {
+			t.Errorf("Screen after growing diff (-got, +want):\n%s", diff)
+		}
+
+		vt.press(t, "\x03")
+		<-errc
+	})
+
+	t.Run("height option", func(t *testing.T) {
+		t.Parallel()
+
+		vt := newVirtualTerminal(20, 8)
+		errc := vt.run(t, func() error {
+			_, err := runList[string](vt, items, ListOptions{Height: Height{Value: 50, Percent: true}}, nil, nil)
+			return err
+		})
+
+		want := []string{">", "item 0", "item 1", "item 2", "", "", "", ""}
+		if diff := cmp.Diff(vt.lines(), want); diff != "" {
+			t.Errorf("Screen diff (-got, +want):\n%s", diff)
+		}
+
+		vt.press(t, "\x03")
+		<-errc
+	})
+
+	t.Run("rows below the cursor", func(t *testing.T) {
+		t.Parallel()
+
+		var many substringList
+		for i := 0; i < 20; i++ {
+			many = append(many, fmt.Sprintf("item %d", i))
+		}
+		vt := newVirtualTerminal(20, 14)
+		vt.screen.Write([]byte("a\r\nb\r\nc\r\n$ "))
+		errc := vt.run(t, func() error {
+			_, err := runList[string](vt, many, ListOptions{}, nil, nil)
+			return err
+		})
+
+		want := []string{"a", "b", "c", "$ >", "item 0", "item 1", "item 2", "item 3", "item 4", "item 5", "item 6", "item 7", "item 8", "item 9"}
+		if diff := cmp.Diff(vt.lines(), want); diff != "" {
+			t.Errorf("Screen diff (-got, +want):\n%s", diff)
+		}
+
+		// Fewer than minRows are left below the cursor, so the terminal is scrolled to make room
+		vt.resize(t, 20, 12)
+		want = []string{"b", "c", "$ >", "item 0", "item 1", "item 2", "item 3", "item 4", "item 5", "item 6", "item 7", "item 8"}
+		if diff := cmp.Diff(vt.lines(), want); diff != "" {
+			t.Errorf("Screen after resizing diff (-got, +want):\n%s", diff)
+		}
+
+		vt.press(t, "\x03")
+		<-errc
+	})
+
+	t.Run("height option on the last row", func(t *testing.T) {
+		t.Parallel()
+
+		vt := newVirtualTerminal(20, 8)
+		vt.screen.Write([]byte("1\r\n2\r\n3\r\n4\r\n5\r\n6\r\n7\r\n$ "))
+		errc := vt.run(t, func() error {
+			_, err := runList[string](vt, items, ListOptions{Height: Height{Value: 4}}, nil, nil)
+			return err
+		})
+
+		want := []string{"4", "5", "6", "7", "$ >", "item 0", "item 1", "item 2"}
+		if diff := cmp.Diff(vt.lines(), want); diff != "" {
+			t.Errorf("Screen diff (-got, +want):\n%s", diff)
+		}
+		if row, col := vt.cursor(); row != 4 || col != 4 {
+			t.Errorf("Got the cursor at %d,%d, want 4,4", row, col)
+		}
+
+		vt.press(t, "\x1b[B\x1b[B\x1b[B")
+		want = []string{"4", "5", "6", "7", "$ >", "item 1", "item 2", "item 3"}
+		if diff := cmp.Diff(vt.lines(), want); diff != "" {
+			t.Errorf("Screen after scrolling diff (-got, +want):\n%s", diff)
+		}
+
+		vt.press(t, "\x03")
+		<-errc
+		want = []string{"4", "5", "6", "7", "$", "", "", ""}
+		if diff := cmp.Diff(vt.lines(), want); diff != "" {
+			t.Errorf("Screen after quitting diff (-got, +want):\n%s", diff)
+		}
+	})
+
+	t.Run("long items", func(t *testing.T) {
+		t.Parallel()
+
+		vt := newVirtualTerminal(10, 4)
+		errc := vt.run(t, func() error {
+			_, err := runList[string](vt, substringList{"kubectl logs -f", "ls"}, ListOptions{}, nil, nil)
+			return err
+		})
+
+		want := []string{">", "kubectl lo", "ls", ""}
+		if diff := cmp.Diff(vt.lines(), want); diff != "" {
+			t.Errorf("Screen diff (-got, +want):\n%s", diff)
+		}
+
+		vt.press(t, "\x03")
+		<-errc
+	})
+}
+
+func TestListMultiScreen(t *testing.T) {
+	t.Parallel()
+
+	vt := newVirtualTerminal(30, 12)
+	var got []string
+	errc := vt.run(t, func() error {
+		var err error
+		got, err = runList[string](vt, substringList{"git log", "git status", "ls"}, ListOptions{}, newComparableMarks[string](), nil)
+		return err
+	})
+
+	// Mark two items and then search for something that matches neither
+	vt.press(t, "\t\x1b[B\t")
+	vt.press(t, "zzz")
+	want := append([]string{"> zzz [2 marked]"}, make([]string, 11)...)
+	if diff := cmp.Diff(vt.lines(), want); diff != "" {
+		t.Errorf("Screen diff (-got, +want):\n%s", diff)
+	}
+
+	vt.press(t, "\r")
+	if err := <-errc; err != nil {
+		t.Fatalf("Unable to select the marked items: %v", err)
+	}
+	if diff := cmp.Diff(got, []string{"git log", "ls"}); diff != "" {
+		t.Errorf("Selection diff (-got, +want):\n%s", diff)
+	}
+}
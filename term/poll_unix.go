@@ -0,0 +1,31 @@
+//go:build !windows
+
+package term
+
+import (
+	"os"
+	"os/signal"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// waitForInput waits until there is input to read from the terminal, returning false if there is
+// none within the timeout.
+func waitForInput(in *os.File, timeout time.Duration) (bool, error) {
+	fds := []unix.PollFd{{Fd: int32(in.Fd()), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(timeout.Milliseconds()))
+	if err == unix.EINTR {
+		// Interrupted by a signal (e.g. a resize), which is treated like a timeout
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// notifyResize relays SIGWINCH, which is sent when the terminal is resized, to the channel. The
+// signal also interrupts waitForInput so that resizes are handled right away.
+func notifyResize(c chan os.Signal) {
+	signal.Notify(c, unix.SIGWINCH)
+}
@@ -0,0 +1,90 @@
+//go:build windows
+
+package term
+
+import (
+	"os"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32              = windows.NewLazySystemDLL("kernel32.dll")
+	procPeekConsoleInputW = kernel32.NewProc("PeekConsoleInputW")
+	procReadConsoleInputW = kernel32.NewProc("ReadConsoleInputW")
+)
+
+// keyEvent is the event type of keyboard events in an INPUT_RECORD.
+const keyEvent = 0x0001
+
+// inputRecord is an INPUT_RECORD from the console's input buffer, with its event laid out as a
+// KEY_EVENT_RECORD (which is only valid if the event type is keyEvent).
+type inputRecord struct {
+	eventType       uint16
+	_               uint16
+	keyDown         int32
+	repeatCount     uint16
+	virtualKeyCode  uint16
+	virtualScanCode uint16
+	char            uint16
+	controlKeyState uint32
+}
+
+// waitForInput waits until there is input to read from the terminal, returning false if there is
+// none within the timeout.
+//
+// The console's handle is also signalled for events that reading from it ignores, such as key
+// releases, mouse movements and focus changes, so reading after those would block. They are
+// discarded until a key press with a character (which is what a read returns) is at the front of
+// the input buffer.
+func waitForInput(in *os.File, timeout time.Duration) (bool, error) {
+	h := windows.Handle(in.Fd())
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining := time.Until(deadline)
+		if remaining < 0 {
+			remaining = 0
+		}
+		event, err := windows.WaitForSingleObject(h, uint32(remaining.Milliseconds()))
+		if err != nil {
+			return false, err
+		} else if event != windows.WAIT_OBJECT_0 {
+			return false, nil
+		}
+
+		ready, err := discardIgnoredEvents(h)
+		if err != nil || ready {
+			return ready, err
+		}
+	}
+}
+
+// discardIgnoredEvents removes events that do not produce any input from the front of the
+// console's input buffer, returning whether there is input to read afterwards.
+func discardIgnoredEvents(h windows.Handle) (bool, error) {
+	for {
+		var rec inputRecord
+		var n uint32
+		if r, _, err := procPeekConsoleInputW.Call(uintptr(h), uintptr(unsafe.Pointer(&rec)), 1, uintptr(unsafe.Pointer(&n))); r == 0 {
+			return false, err
+		} else if n == 0 {
+			return false, nil
+		}
+
+		// Modifier keys are pressed without a character, while other special keys (e.g. the arrow
+		// keys) are sent as escape sequences in virtual terminal mode
+		if rec.eventType == keyEvent && rec.keyDown != 0 && rec.char != 0 {
+			return true, nil
+		}
+
+		if r, _, err := procReadConsoleInputW.Call(uintptr(h), uintptr(unsafe.Pointer(&rec)), 1, uintptr(unsafe.Pointer(&n))); r == 0 {
+			return false, err
+		}
+	}
+}
+
+// notifyResize does nothing as Windows does not signal resizes, which are instead detected by
+// comparing the size of the terminal whenever it is polled.
+func notifyResize(c chan os.Signal) {}
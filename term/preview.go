@@ -0,0 +1,74 @@
+package term
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/pterm/pterm"
+)
+
+// PreviewField is a single labelled piece of content in the preview pane.
+type PreviewField struct {
+	Label string
+	Value string
+}
+
+// Previewer is an optional interface that the payloads of list items can implement to supply the
+// content of the preview pane, which shows the selected item in full. Fields with empty values are
+// not shown.
+type Previewer interface {
+	Preview() []PreviewField
+}
+
+// renderPreview lays out the fields as a separator followed by a two-column grid of labels and
+// values, wrapping the values to fit within the given width.
+func renderPreview(fields []PreviewField, width int) string {
+	labelWidth := 0
+	for _, f := range fields {
+		if f.Value != "" {
+			labelWidth = max(labelWidth, runewidth.StringWidth(f.Label))
+		}
+	}
+
+	lines := []string{pterm.Gray(strings.Repeat("─", max(width, 1)))}
+
+	// A label is always shown, even if the terminal is too narrow to show any of the value
+	valueWidth := max(width-labelWidth-1, 1)
+	for _, f := range fields {
+		if f.Value == "" {
+			continue
+		}
+
+		label := runewidth.FillRight(f.Label, labelWidth)
+		for i, line := range wrap(f.Value, valueWidth) {
+			if i > 0 {
+				label = strings.Repeat(" ", labelWidth)
+			}
+			lines = append(lines, pterm.Bold.Sprint(label)+" "+line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// wrap splits the text into lines that are at most width columns wide, breaking at existing new
+// lines and otherwise at the width itself so that the text (e.g. a command) is shown exactly.
+func wrap(s string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		var current strings.Builder
+		col := 0
+		for _, r := range line {
+			w := runewidth.RuneWidth(r)
+			if col+w > width && col > 0 {
+				lines = append(lines, current.String())
+				current.Reset()
+				col = 0
+			}
+			current.WriteRune(r)
+			col += w
+		}
+		lines = append(lines, current.String())
+	}
+	return lines
+}
@@ -0,0 +1,78 @@
+package term
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pterm/pterm"
+)
+
+func TestRenderPreview(t *testing.T) {
+	tests := []struct {
+		msg    string
+		fields []PreviewField
+		width  int
+		want   []string
+	}{
+		{
+			msg: "Short values",
+			fields: []PreviewField{
+				{Label: "Invocation", Value: "git status"},
+				{Label: "Tags", Value: "#git"},
+			},
+			width: 30,
+			want: []string{
+				strings.Repeat("─", 30),
+				"Invocation git status",
+				"Tags       #git",
+			},
+		},
+		{
+			msg: "Empty values are skipped",
+			fields: []PreviewField{
+				{Label: "Invocation", Value: "ls"},
+				{Label: "Description", Value: ""},
+			},
+			width: 20,
+			want: []string{
+				strings.Repeat("─", 20),
+				"Invocation ls",
+			},
+		},
+		{
+			msg: "Long and multi-line values are wrapped",
+			fields: []PreviewField{
+				{Label: "Cmd", Value: "kubectl get pods | grep café\necho done"},
+			},
+			width: 14,
+			want: []string{
+				strings.Repeat("─", 14),
+				"Cmd kubectl ge",
+				"    t pods | g",
+				"    rep café",
+				"    echo done",
+			},
+		},
+		{
+			msg: "Wide characters are wrapped by display width",
+			fields: []PreviewField{
+				{Label: "説明", Value: "ログを表示する"},
+			},
+			width: 11,
+			want: []string{
+				strings.Repeat("─", 11),
+				"説明 ログを",
+				"     表示す",
+				"     る",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got := strings.Split(pterm.RemoveColorFromString(renderPreview(tt.fields, tt.width)), "\n")
+		if diff := cmp.Diff(got, tt.want); diff != "" {
+			t.Errorf("%s: preview diff (-got, +want):\n%s", tt.msg, diff)
+		}
+	}
+}
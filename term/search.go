@@ -0,0 +1,129 @@
+package term
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CancellableList is a QueryableList whose searches can be cancelled. Searches that are no longer
+// needed, such as when the user has typed another character, are cancelled through the context.
+type CancellableList[T any] interface {
+	QueryableList[T]
+	SearchContext(ctx context.Context, query string) ([]ListItem[T], error)
+}
+
+type searchResult[T any] struct {
+	seq   int
+	items []ListItem[T]
+	err   error
+}
+
+// asyncSearch runs searches in the background so that the interface remains responsive while a
+// search is running. Only the results of the most recently started search are returned: older
+// searches are cancelled (if the list supports it) and their results are discarded.
+type asyncSearch[T any] struct {
+	search  func(ctx context.Context, query string) ([]ListItem[T], error)
+	results chan searchResult[T]
+
+	// The sequence number of the most recently started search.
+	seq     int
+	cancel  context.CancelFunc
+	running bool
+	started time.Time
+	// Tracks the goroutines of all started searches, including cancelled ones.
+	wg sync.WaitGroup
+}
+
+func newAsyncSearch[T any](list QueryableList[T]) *asyncSearch[T] {
+	s := &asyncSearch[T]{results: make(chan searchResult[T])}
+	if cl, ok := list.(CancellableList[T]); ok {
+		s.search = cl.SearchContext
+	} else {
+		s.search = func(_ context.Context, query string) ([]ListItem[T], error) {
+			return list.Search(query)
+		}
+	}
+	return s
+}
+
+// start starts a search for the query, cancelling any search that is already running.
+func (s *asyncSearch[T]) start(query string) {
+	s.stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.seq++
+	s.cancel = cancel
+	s.running = true
+	s.started = time.Now()
+
+	seq := s.seq
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		items, err := s.search(ctx, query)
+		select {
+		case s.results <- searchResult[T]{seq: seq, items: items, err: err}:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// poll returns the results of the current search if it has finished, without blocking.
+func (s *asyncSearch[T]) poll() (searchResult[T], bool) {
+	for s.running {
+		select {
+		case r := <-s.results:
+			if s.accept(r) {
+				return r, true
+			}
+		default:
+			return searchResult[T]{}, false
+		}
+	}
+	return searchResult[T]{}, false
+}
+
+// wait blocks until the current search finishes and returns its results. It should only be called
+// while a search is running.
+func (s *asyncSearch[T]) wait() searchResult[T] {
+	for {
+		if r := <-s.results; s.accept(r) {
+			return r
+		}
+	}
+}
+
+// accept determines whether the result belongs to the current search, marking the search as
+// finished if it does.
+func (s *asyncSearch[T]) accept(r searchResult[T]) bool {
+	if r.seq != s.seq {
+		return false
+	}
+	s.running = false
+	s.cancel()
+	return true
+}
+
+// elapsed returns how long the current search has been running for.
+func (s *asyncSearch[T]) elapsed() time.Duration {
+	if !s.running {
+		return 0
+	}
+	return time.Since(s.started)
+}
+
+// close cancels the current search and waits for every search to return, after which the list
+// can safely be modified.
+func (s *asyncSearch[T]) close() {
+	s.stop()
+	s.wg.Wait()
+}
+
+// stop cancels the current search, if any.
+func (s *asyncSearch[T]) stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.running = false
+}
@@ -0,0 +1,81 @@
+package term
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// blockingList is a CancellableList whose searches block until they are released or cancelled.
+type blockingList struct {
+	release   map[string]chan struct{}
+	cancelled chan string
+}
+
+func (l *blockingList) Search(query string) ([]ListItem[string], error) {
+	return l.SearchContext(context.Background(), query)
+}
+
+func (l *blockingList) SearchContext(ctx context.Context, query string) ([]ListItem[string], error) {
+	select {
+	case <-l.release[query]:
+		return []ListItem[string]{{Raw: query}}, nil
+	case <-ctx.Done():
+		l.cancelled <- query
+		return nil, ctx.Err()
+	}
+}
+
+func TestAsyncSearch(t *testing.T) {
+	l := &blockingList{
+		release:   map[string]chan struct{}{"a": make(chan struct{}), "ab": make(chan struct{})},
+		cancelled: make(chan string, 2),
+	}
+	s := newAsyncSearch[string](l)
+	defer s.close()
+
+	s.start("a")
+	if _, ok := s.poll(); ok {
+		t.Fatalf("Got results before the search finished")
+	}
+
+	// Starting a new search cancels the old one
+	s.start("ab")
+	select {
+	case q := <-l.cancelled:
+		if q != "a" {
+			t.Errorf("Search for %q was cancelled, want %q", q, "a")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("The outdated search was not cancelled")
+	}
+
+	close(l.release["ab"])
+	r := s.wait()
+	if r.err != nil || len(r.items) != 1 || r.items[0].Raw != "ab" {
+		t.Errorf("Got results %v with error %v, want the results for %q", r.items, r.err, "ab")
+	}
+	if s.running {
+		t.Errorf("The search is still running after its results were returned")
+	}
+}
+
+func TestAsyncSearchDiscardsStaleResults(t *testing.T) {
+	s := &asyncSearch[string]{results: make(chan searchResult[string], 2)}
+	s.search = func(ctx context.Context, query string) ([]ListItem[string], error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	defer s.close()
+
+	s.start("a")
+	s.start("ab")
+	// A result from an outdated search arriving before the current one is ignored
+	s.results <- searchResult[string]{seq: s.seq - 1, items: []ListItem[string]{{Raw: "a"}}}
+	s.results <- searchResult[string]{seq: s.seq, items: []ListItem[string]{{Raw: "ab"}}}
+
+	r, ok := s.poll()
+	if !ok || len(r.items) != 1 || r.items[0].Raw != "ab" {
+		t.Errorf("Got results %v (finished: %t), want the results for %q", r.items, ok, "ab")
+	}
+}
@@ -0,0 +1,44 @@
+package term
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rithvikp/speeddial/term/termui"
+)
+
+// Terminal is a terminal in raw mode that the interactive interfaces in this package (e.g. List)
+// read keyboard events from and write their output to.
+type Terminal interface {
+	io.Writer
+	termui.Terminal
+
+	// GetKeyboardEvent blocks until there is a keyboard event, and then returns it.
+	GetKeyboardEvent() (*Event, error)
+	// PollKeyboardEvent is like GetKeyboardEvent, but it gives up and returns a nil event if there
+	// is no keyboard event within the timeout.
+	PollKeyboardEvent(timeout time.Duration) (*Event, error)
+	// Resized determines whether the terminal has been resized since the last call.
+	Resized() bool
+	// Stop restores the terminal to its previous state.
+	Stop() error
+	// Start switches the terminal back to raw mode after Stop, e.g. once a command that used the
+	// terminal in between has finished.
+	Start() error
+}
+
+// openTty opens a Tty for one of the interactive interfaces, along with a function that restores
+// the terminal once the interface has returned.
+func openTty() (Terminal, func(), error) {
+	t, err := NewTty()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to initialize the terminal interface: %v", err)
+	}
+	return t, func() {
+		if err := t.Stop(); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to restore the terminal interface: %v", err)
+		}
+	}, nil
+}
@@ -0,0 +1,215 @@
+package term
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rithvikp/speeddial/term/vt100"
+)
+
+// settleTimeout is how long tests wait for an interface to finish handling keystrokes.
+const settleTimeout = 5 * time.Second
+
+// virtualTerminal is a Terminal that is backed by an in-memory screen, with keystrokes that are
+// scripted by tests.
+type virtualTerminal struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	screen *vt100.Screen
+	// Keystrokes that have not been read yet.
+	input   []byte
+	resized bool
+
+	// The interface has settled once it waits for a keystroke without having written anything
+	// since it last polled for one (as it is periodically updated while a search is running).
+	writes     int
+	pollWrites int
+	idle       bool
+	done       bool
+}
+
+// newVirtualTerminal creates a blank virtual terminal with the given size.
+func newVirtualTerminal(width, height int) *virtualTerminal {
+	vt := &virtualTerminal{screen: vt100.NewScreen(width, height)}
+	vt.cond = sync.NewCond(&vt.mu)
+	return vt
+}
+
+// run runs an interface in the terminal, returning once the interface has first settled. The
+// interface's error is sent on the channel once it returns.
+func (vt *virtualTerminal) run(t *testing.T, ui func() error) <-chan error {
+	t.Helper()
+
+	errc := make(chan error, 1)
+	go func() {
+		err := ui()
+		vt.mu.Lock()
+		vt.done = true
+		vt.cond.Broadcast()
+		vt.mu.Unlock()
+		errc <- err
+	}()
+
+	vt.settle(t)
+	return errc
+}
+
+// press sends the keystrokes to the interface and waits for it to settle (or return).
+func (vt *virtualTerminal) press(t *testing.T, keys string) {
+	t.Helper()
+
+	vt.mu.Lock()
+	vt.input = append(vt.input, keys...)
+	vt.idle = false
+	vt.cond.Broadcast()
+	vt.mu.Unlock()
+
+	vt.settle(t)
+}
+
+// resize changes the size of the terminal and waits for the interface to settle.
+func (vt *virtualTerminal) resize(t *testing.T, width, height int) {
+	t.Helper()
+
+	vt.mu.Lock()
+	vt.screen.Resize(width, height)
+	vt.resized = true
+	vt.idle = false
+	vt.mu.Unlock()
+
+	vt.settle(t)
+}
+
+func (vt *virtualTerminal) settle(t *testing.T) {
+	t.Helper()
+
+	timedOut := false
+	timer := time.AfterFunc(settleTimeout, func() {
+		vt.mu.Lock()
+		timedOut = true
+		vt.cond.Broadcast()
+		vt.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	for !vt.idle && !vt.done && !timedOut {
+		vt.cond.Wait()
+	}
+	if timedOut {
+		t.Fatalf("The interface did not settle, showing:\n%s", vt.screen)
+	}
+}
+
+// lines returns the text on each line of the screen.
+func (vt *virtualTerminal) lines() []string {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return vt.screen.Lines()
+}
+
+// boldText returns the text in bold on the screen (e.g. the selected item of a list).
+func (vt *virtualTerminal) boldText() string {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+
+	var b strings.Builder
+	width, height := vt.screen.Size()
+	for row := 0; row < height; row++ {
+		for col := 0; col < width; col++ {
+			if c := vt.screen.Cell(row, col); c.Bold && c.Rune > 0 {
+				b.WriteRune(c.Rune)
+			}
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// cursor returns the (zero-based) row and column of the cursor.
+func (vt *virtualTerminal) cursor() (row, col int) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return vt.screen.Cursor()
+}
+
+func (vt *virtualTerminal) Write(p []byte) (int, error) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	vt.writes++
+	return vt.screen.Write(p)
+}
+
+func (vt *virtualTerminal) Size() (width, height int, err error) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	width, height = vt.screen.Size()
+	return width, height, nil
+}
+
+func (vt *virtualTerminal) CursorPosition() (row, col int, err error) {
+	row, col = vt.cursor()
+	return row + 1, col + 1, nil
+}
+
+// next decodes the next keystroke, if there is one. The lock must be held.
+func (vt *virtualTerminal) next() *Event {
+	e, n := decodeEvent(vt.input)
+	if n == 0 {
+		return nil
+	}
+	vt.input = vt.input[n:]
+	return &e
+}
+
+func (vt *virtualTerminal) GetKeyboardEvent() (*Event, error) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	for {
+		if e := vt.next(); e != nil {
+			return e, nil
+		}
+		vt.idle = true
+		vt.cond.Broadcast()
+		vt.cond.Wait()
+	}
+}
+
+func (vt *virtualTerminal) PollKeyboardEvent(timeout time.Duration) (*Event, error) {
+	vt.mu.Lock()
+	if e := vt.next(); e != nil {
+		vt.mu.Unlock()
+		return e, nil
+	}
+	if vt.writes == vt.pollWrites {
+		vt.idle = true
+		vt.cond.Broadcast()
+	}
+	vt.pollWrites = vt.writes
+	vt.mu.Unlock()
+
+	// Time is not simulated, but there is no need to wait for the full timeout either
+	time.Sleep(min(timeout, time.Millisecond))
+
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return vt.next(), nil
+}
+
+func (vt *virtualTerminal) Resized() bool {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	resized := vt.resized
+	vt.resized = false
+	return resized
+}
+
+func (vt *virtualTerminal) Stop() error {
+	return nil
+}
+
+func (vt *virtualTerminal) Start() error {
+	return nil
+}
@@ -4,13 +4,26 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/mattn/go-runewidth"
 	"github.com/pterm/pterm"
 	"golang.org/x/term"
 )
 
+// Terminal is the terminal that a Builder's output is shown in.
+type Terminal interface {
+	// Size returns the width and height of the terminal.
+	Size() (width, height int, err error)
+	// CursorPosition returns the (one-based) row and column of the cursor.
+	CursorPosition() (row, col int, err error)
+}
+
 // Builder builds inline UIs for terminal CLIs.
 type Builder struct {
 	*builder
+	// Terminal, if set, is queried for the terminal's width and the cursor's position. Otherwise,
+	// the width is that of the terminal whose input is fd and the cursor is assumed to be at the
+	// start of a line when it is saved.
+	Terminal Terminal
 	// The file descriptor which corresponds to the input from the terminal. This defaults to stdin.
 	fd int
 }
@@ -18,7 +31,11 @@ type Builder struct {
 type builder struct {
 	content        strings.Builder
 	linesSinceSave int
-	saveCol        int
+	// The (zero-based) columns of the cursor when it was saved and after the current contents.
+	saveCol int
+	col     int
+	// The (one-based) row of the savepoint, or zero if it is unknown.
+	saveRow int
 }
 
 func (b *Builder) init() {
@@ -27,16 +44,34 @@ func (b *Builder) init() {
 	}
 }
 
-func (b *Builder) width() int {
+func (b *Builder) size() (width, height int) {
 	b.init()
 
-	width, _, err := term.GetSize(b.fd)
+	var err error
+	if b.Terminal != nil {
+		width, height, err = b.Terminal.Size()
+	} else {
+		width, height, err = term.GetSize(b.fd)
+	}
 	if err != nil {
 		panic(err)
 	}
+	return width, height
+}
+
+func (b *Builder) width() int {
+	width, _ := b.size()
 	return width
 }
 
+// write adds text to the buffer, keeping track of where the cursor ends up.
+func (b *Builder) write(text string) {
+	lines, col := layoutInWidth(text, b.width(), b.col)
+	b.linesSinceSave += lines
+	b.col = col
+	b.content.WriteString(text)
+}
+
 // Commit dumps the current contents to a strings and resets the internal buffer.
 func (b *Builder) Commit() string {
 	b.init()
@@ -51,6 +86,7 @@ func (b *Builder) NextLine() *Builder {
 	b.init()
 
 	b.linesSinceSave++
+	b.col = 0
 	b.content.WriteString(vt100ClearEOL() + "\n\r")
 	return b
 }
@@ -63,13 +99,9 @@ func (b *Builder) NextLine() *Builder {
 func (b *Builder) WriteStringAndReformat(text string) *Builder {
 	b.init()
 
-	b.linesSinceSave += numNewLinesInWidth(text, b.width())
-
 	// Since the terminal is in raw mode, carriage returns as well as clearing
-	// the remainder of the line are necessary. This must be performed after counting
-	// the number of new lines as numNewLinesInWidth does not ignore vt100 codes
-	text = strings.ReplaceAll(text, "\n", vt100ClearEOL()+"\r\n")
-	b.content.WriteString(text)
+	// the remainder of the line are necessary
+	b.write(strings.ReplaceAll(text, "\n", vt100ClearEOL()+"\r\n"))
 	return b
 }
 
@@ -77,8 +109,7 @@ func (b *Builder) WriteStringAndReformat(text string) *Builder {
 func (b *Builder) WriteString(text string) *Builder {
 	b.init()
 
-	b.linesSinceSave += numNewLinesInWidth(text, b.width())
-	b.content.WriteString(text)
+	b.write(text)
 	return b
 }
 
@@ -99,27 +130,62 @@ func (b *Builder) ClearToScreenEnd() *Builder {
 }
 
 // SaveCursor saves the current position of the cursor for future resets. The vertical position
-// is not a row number but an actual line (so may move if the window is scrolled).
+// is not a row number but an actual line (so may move if the window is scrolled). The position is
+// queried from the Terminal, if there is one. Otherwise (e.g. if the terminal does not report the
+// cursor's position in time), the cursor is assumed to be at the start of a line and its row is
+// unknown.
 func (b *Builder) SaveCursor() *Builder {
 	b.init()
 
 	b.linesSinceSave = 0
-	b.saveCol = 0 // FIXME: Fetch col
+	b.saveCol = 0
+	b.saveRow = 0
+	if b.Terminal != nil {
+		if row, col, err := b.Terminal.CursorPosition(); err == nil && row > 0 && col > 0 {
+			b.saveRow = row
+			b.saveCol = col - 1
+		}
+	}
+	b.col = b.saveCol
 	return b
 }
 
-// ResetCursor moves the cursor back to the last savepoint.
-func (b *Builder) ResetCursor() *Builder {
+// RowsBelowSave returns the number of rows from the savepoint to the bottom of the terminal,
+// including the savepoint's own row. If the savepoint's row is unknown, the whole terminal is
+// assumed to be available.
+func (b *Builder) RowsBelowSave() int {
+	_, height := b.size()
+	if b.saveRow == 0 || b.saveRow > height {
+		return height
+	}
+	return height - b.saveRow + 1
+}
+
+// ReserveRows scrolls the terminal up, if needed, so that there are at least the given number of
+// rows from the savepoint downwards. The cursor must be at the savepoint, where it stays.
+func (b *Builder) ReserveRows(rows int) *Builder {
 	b.init()
 
-	lines := b.linesSinceSave
-	b.linesSinceSave = 0
+	scroll := rows - b.RowsBelowSave()
+	if scroll <= 0 {
+		return b
+	}
+	// Line feeds keep the cursor's column, and scroll the terminal once they reach the bottom
+	b.content.WriteString(strings.Repeat("\n", rows-1) + vt100CursorUp(rows-1))
+	b.saveRow -= scroll
+	return b
+}
+
+// ResetCursor moves the cursor back to the last savepoint, restoring both its line and column.
+func (b *Builder) ResetCursor() *Builder {
+	b.init()
 
 	actions := make([]CursorAction, 0, 3)
 	actions = append(actions, CursorLineStart())
-	if lines > 0 {
-		actions = append(actions, CursorUp(lines))
-	} else if b.saveCol > 0 {
+	if b.linesSinceSave > 0 {
+		actions = append(actions, CursorUp(b.linesSinceSave))
+	}
+	if b.saveCol > 0 {
 		actions = append(actions, CursorRight(b.saveCol))
 	}
 
@@ -132,50 +198,83 @@ func (b *Builder) MoveCursor(actions ...CursorAction) *Builder {
 	b.init()
 
 	for _, a := range actions {
-		b.content.WriteString(string(a))
+		if a.lineStart {
+			b.col = 0
+		}
+		b.linesSinceSave -= a.up
+		if b.linesSinceSave < 0 {
+			b.linesSinceSave = 0
+		}
+		if a.right > 0 {
+			// The cursor stops at the last column
+			if b.col += a.right; b.col >= b.width() {
+				b.col = b.width() - 1
+			}
+		}
+		b.content.WriteString(a.seq)
 	}
 	return b
 }
 
 // CursorAction represents some movement that the cursor should perform.
-type CursorAction string
+type CursorAction struct {
+	seq string
+	// How the action moves the cursor, which the Builder keeps track of.
+	up, right int
+	lineStart bool
+}
 
 // CursorRight translates the cursor right by the given number of columns.
 func CursorRight(cols int) CursorAction {
-	return CursorAction(vt100CursorRight(cols))
+	return CursorAction{seq: vt100CursorRight(cols), right: cols}
 }
 
 // CursorUp translates the cursor up by the given number of columns.
 func CursorUp(rows int) CursorAction {
-	return CursorAction(vt100CursorUp(rows))
+	return CursorAction{seq: vt100CursorUp(rows), up: rows}
 }
 
 // CursorLineStart moves the cursor to the beginning of the current line.
 func CursorLineStart() CursorAction {
-	return CursorAction("\r")
+	return CursorAction{seq: "\r", lineStart: true}
 }
 
 // numNewLinesInWidth returns the number of new lines (after wrapping) taken up by the
-// given string when shown on a screen with the given width. There are some
-// significant known limitations to the implementation (including support
-// for vt100 escape codes, tabs etc.).
-//
-// Keep track of the number of characters in the current line, adding a new line
-// when the max width is reached.
+// given string when shown on a screen with the given width, starting at the beginning of a line.
 func numNewLinesInWidth(s string, width int) int {
-	lines := 0
-	charsInLine := 0
+	lines, _ := layoutInWidth(s, width, 0)
+	return lines
+}
+
+// layoutInWidth returns the number of new lines (after wrapping) taken up by the given string
+// when shown on a screen with the given width, starting at the given column, along with the column
+// that the string ends at. There are some significant known limitations to the implementation
+// (including support for vt100 escape codes other than colors, tabs etc.).
+//
+// Keep track of the display width of the current line, adding a new line when the next
+// character does not fit. Wide characters (e.g. CJK and emoji) take up two columns, and a
+// line that exactly fills the width does not wrap until another character is written.
+func layoutInWidth(s string, width, col int) (lines, endCol int) {
 	s = pterm.RemoveColorFromString(s)
 
 	for _, c := range s {
-		if c == '\n' || charsInLine > width {
-			charsInLine = 0
+		if c == '\n' {
+			col = 0
 			lines++
-		} else if c != '\r' {
-			charsInLine++
+			continue
+		} else if c == '\r' {
+			col = 0
+			continue
 		}
+
+		w := runewidth.RuneWidth(c)
+		if col+w > width {
+			col = 0
+			lines++
+		}
+		col += w
 	}
-	return lines
+	return lines, col
 }
 
 // Define functions for manipulating the screen of a VT100 terminal. These are defined as functinos
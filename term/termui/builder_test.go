@@ -1,6 +1,8 @@
 package termui
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -47,6 +49,30 @@ func TestNumNewLinesInWidth(t *testing.T) {
 			width: 50,
 			lines: 3,
 		},
+		{
+			msg:   "Line that exactly fills the width",
+			input: "0123456789\nnext",
+			width: 10,
+			lines: 1,
+		},
+		{
+			msg:   "Accented characters take up a single column",
+			input: "café crème brûlée",
+			width: 17,
+			lines: 0,
+		},
+		{
+			msg:   "Wide characters take up two columns",
+			input: "日本語のコマンド",
+			width: 10,
+			lines: 1,
+		},
+		{
+			msg:   "Wide character that does not fit at the end of a line",
+			input: "abc😀",
+			width: 4,
+			lines: 1,
+		},
 	}
 
 	for _, tt := range tests {
@@ -60,3 +86,135 @@ func TestNumNewLinesInWidth(t *testing.T) {
 		})
 	}
 }
+
+// fakeTerminal is a terminal of a fixed width with the cursor at a fixed column.
+type fakeTerminal struct {
+	width int
+	col   int
+	err   error
+}
+
+func (t *fakeTerminal) Size() (int, int, error) {
+	return t.width, 24, nil
+}
+
+func (t *fakeTerminal) CursorPosition() (int, int, error) {
+	return 5, t.col, t.err
+}
+
+func TestBuilderCursorTracking(t *testing.T) {
+	tests := []struct {
+		msg   string
+		term  *fakeTerminal
+		build func(b *Builder)
+		want  string
+	}{
+		{
+			msg:  "start of a line",
+			term: &fakeTerminal{width: 20, col: 1},
+			build: func(b *Builder) {
+				b.WriteString("abc").NextLine().WriteString("def")
+			},
+			want: "\r\x1b[1A",
+		},
+		{
+			msg:  "restores the column",
+			term: &fakeTerminal{width: 20, col: 6},
+			build: func(b *Builder) {
+				b.WriteString("abc")
+			},
+			want: "\r\x1b[5C",
+		},
+		{
+			msg:  "restores the line and column",
+			term: &fakeTerminal{width: 20, col: 6},
+			build: func(b *Builder) {
+				b.WriteStringAndReformat("abc\ndef\nghi")
+			},
+			want: "\r\x1b[2A\x1b[5C",
+		},
+		{
+			msg:  "text wraps earlier after the saved column",
+			term: &fakeTerminal{width: 10, col: 7},
+			build: func(b *Builder) {
+				b.WriteString("abcdef")
+			},
+			want: "\r\x1b[1A\x1b[6C",
+		},
+		{
+			msg:  "cursor movements",
+			term: &fakeTerminal{width: 10, col: 3},
+			build: func(b *Builder) {
+				b.NextLine().NextLine().MoveCursor(CursorUp(1), CursorLineStart()).WriteString("abcdefghijkl")
+			},
+			want: "\r\x1b[2A\x1b[2C",
+		},
+		{
+			msg:  "unknown position",
+			term: &fakeTerminal{width: 20, col: 6, err: errors.New("timed out")},
+			build: func(b *Builder) {
+				b.WriteString("abc").NextLine()
+			},
+			want: "\r\x1b[1A",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			b := &Builder{Terminal: tt.term}
+			b.SaveCursor()
+			tt.build(b)
+			b.Commit()
+
+			if got := b.ResetCursor().Commit(); got != tt.want {
+				t.Errorf("Got cursor reset %q, want %q", got, tt.want)
+			}
+			// The cursor is already on the saved line, so resetting it again only restores the column
+			want := "\r"
+			if b.saveCol > 0 {
+				want += vt100CursorRight(b.saveCol)
+			}
+			if got := b.ResetCursor().Commit(); got != want {
+				t.Errorf("Got second cursor reset %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestBuilderReserveRows(t *testing.T) {
+	tests := []struct {
+		msg       string
+		term      *fakeTerminal
+		rows      int
+		want      string
+		wantBelow int
+	}{
+		{msg: "enough rows", term: &fakeTerminal{width: 20, col: 1}, rows: 20, want: "", wantBelow: 20},
+		{
+			msg:       "scrolls",
+			term:      &fakeTerminal{width: 20, col: 1},
+			rows:      22,
+			want:      strings.Repeat("\n", 21) + "\x1b[21A",
+			wantBelow: 22,
+		},
+		{
+			msg:       "unknown position",
+			term:      &fakeTerminal{width: 20, col: 1, err: errors.New("timed out")},
+			rows:      24,
+			want:      "",
+			wantBelow: 24,
+		},
+	}
+
+	for _, tt := range tests {
+		b := &Builder{Terminal: tt.term}
+		b.SaveCursor()
+		if got := b.ReserveRows(tt.rows).Commit(); got != tt.want {
+			t.Errorf("%s: got %q when reserving rows, want %q", tt.msg, got, tt.want)
+		}
+		if got := b.RowsBelowSave(); got != tt.wantBelow {
+			t.Errorf("%s: got %d rows below the savepoint, want %d", tt.msg, got, tt.wantBelow)
+		}
+	}
+}
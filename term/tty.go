@@ -1,15 +1,31 @@
 package term
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strconv"
+	"time"
+	"unicode/utf8"
 
 	"golang.org/x/term"
 )
 
-// Tty represents a raw terminal interface.
+// Tty represents a raw terminal interface, which reads keyboard events from stdin and writes to
+// stderr. It implements Terminal.
 type Tty struct {
+	in       *os.File
+	out      io.Writer
 	oldState *term.State
+	// Input that has been read from the terminal but not yet decoded into events.
+	pending []byte
+	// Receives a signal whenever the terminal is resized (on platforms that have one), along with
+	// the size of the terminal when it was last checked.
+	resize        chan os.Signal
+	width, height int
 }
 
 // Key represents keyboard keys.
@@ -29,80 +45,378 @@ const (
 const (
 	KeyUp Key = iota + 512
 	KeyDown
+	KeyLeft
+	KeyRight
+	KeyHome
+	KeyEnd
+	KeyForwardDelete
+	// KeyPaste is a bracketed paste, with the pasted text in the event's text field.
+	KeyPaste
+	// keyCursorPosition is the terminal's report of the cursor's position (see CursorPosition),
+	// with the position in the event's row and col fields. Reports that arrive too late are
+	// dropped rather than returned as keyboard events.
+	keyCursorPosition
+	KeyUnknown
 )
 
-// Define even more keys.
+// Define even more keys. Control keys have the same value as the byte that the terminal sends.
 const (
+	KeyCtrlD  Key = 4
+	KeyCtrlE  Key = 5
+	KeyCtrlF  Key = 6
+	KeyCtrlH  Key = 8
+	KeyTab    Key = 9
+	KeyCtrlK  Key = 11
 	KeyEnter  Key = 13
+	KeyCtrlO  Key = 15
+	KeyCtrlP  Key = 16
+	KeyCtrlR  Key = 18
+	KeyCtrlU  Key = 21
+	KeyCtrlW  Key = 23
+	KeyCtrlX  Key = 24
+	KeyCtrlY  Key = 25
 	KeyEscape Key = 27
 	KeyDelete Key = 127
 )
 
+// Modifier is a set of modifier keys that were held down during a keyboard event.
+type Modifier int
+
+// Define the modifier keys, which can be combined.
+const (
+	ModShift Modifier = 1 << iota
+	ModAlt
+	ModCtrl
+)
+
 // Event represents a keyboard event. If the Key is KeyChar, the char field should be checked for
 // the specific character that was pressed.
 type Event struct {
 	key  Key
 	char rune
+	mod  Modifier
+	text string
+	row  int
+	col  int
 }
 
+// Escape sequences for bracketed paste, which is enabled while the Tty is in use so that pasted
+// text is not interpreted as key presses.
+const (
+	enableBracketedPaste  = "\033[?2004h"
+	disableBracketedPaste = "\033[?2004l"
+	pasteStart            = "\033[200~"
+	pasteEnd              = "\033[201~"
+)
+
 // NewTty creates a new Tty. It has a side-effect of switching the current terminal to raw mode.
 func NewTty() (*Tty, error) {
-	t := Tty{}
+	t := &Tty{in: os.Stdin, out: os.Stderr, resize: make(chan os.Signal, 1)}
+	if err := t.Start(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
 
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+// Start switches the current terminal to raw mode, which NewTty already does. It is used to
+// resume using the Tty after Stop.
+func (t *Tty) Start() error {
+	oldState, err := term.MakeRaw(int(t.in.Fd()))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	t.oldState = oldState
+	fmt.Fprint(t, enableBracketedPaste)
 
-	return &t, err
+	notifyResize(t.resize)
+	t.width, t.height, _ = t.Size()
+	return nil
 }
 
 // GetKeyboardEvent blocks until there is a keyboard event, and then returns it.
 func (t *Tty) GetKeyboardEvent() (*Event, error) {
-	buf := make([]byte, 5)
-	n, err := os.Stdin.Read(buf)
+	for {
+		if e, n := decodeEvent(t.pending); n > 0 {
+			t.pending = t.pending[n:]
+			if e.key == keyCursorPosition {
+				continue
+			}
+			return &e, nil
+		}
+
+		if err := t.read(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// PollKeyboardEvent is like GetKeyboardEvent, but it gives up and returns a nil event if there
+// is no keyboard event within the timeout.
+func (t *Tty) PollKeyboardEvent(timeout time.Duration) (*Event, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if e, n := decodeEvent(t.pending); n > 0 {
+			t.pending = t.pending[n:]
+			if e.key == keyCursorPosition {
+				continue
+			}
+			return &e, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, nil
+		}
+		ready, err := waitForInput(t.in, remaining)
+		if err != nil {
+			return nil, err
+		} else if !ready {
+			return nil, nil
+		}
+
+		if err := t.read(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// read reads the next chunk of input from the terminal, blocking until it is available.
+func (t *Tty) read() error {
+	buf := make([]byte, 256)
+	n, err := t.in.Read(buf)
 	if err != nil {
-		return nil, err
+		return err
 	} else if n == 0 {
-		return nil, errors.New("unable to read any characters from tty")
+		return errors.New("unable to read any characters from tty")
 	}
+	t.pending = append(t.pending, buf[:n]...)
+	return nil
+}
 
-	buf = buf[:n]
+// decodeEvent decodes the first event in the input, returning it along with the number of bytes
+// that it took up. If the input does not yet contain a complete event, zero bytes are consumed.
+//
+// An escape byte at the end of the input is treated as the escape key: terminals send escape
+// sequences all at once, so it is very unlikely that a sequence would be split across reads.
+func decodeEvent(in []byte) (Event, int) {
+	if len(in) == 0 {
+		return Event{}, 0
+	}
 
-	e := Event{}
-	// Handle regular characters
-	if n == 1 && buf[0] >= ' ' && buf[0] <= '~' {
-		e.key = KeyChar
-		e.char = rune(buf[0])
-		return &e, nil
+	if in[0] != byte(KeyEscape) {
+		return decodeChar(in)
+	} else if len(in) == 1 {
+		return Event{key: KeyEscape}, 1
 	}
 
-	// TODO: Improve key matching (match more characters, match arrows in a better manner etc.)
-	switch int(buf[0]) {
-	case int(KeyCtrlC):
-		e.key = KeyCtrlC
-	case int(KeyEnter):
-		e.key = KeyEnter
-	case int(KeyEscape):
-		e.key = KeyEscape
-	case int(KeyDelete):
-		e.key = KeyDelete
+	switch in[1] {
+	case '[':
+		return decodeCSI(in)
+	case 'O':
+		// SS3 sequences are sent for some keys when the terminal is in application mode
+		if len(in) < 3 {
+			return Event{}, 0
+		}
+		e := Event{key: KeyUnknown}
+		if k, ok := csiFinalKeys[in[2]]; ok {
+			e.key = k
+		}
+		return e, 3
+	case byte(KeyEscape):
+		return Event{key: KeyEscape}, 1
 	}
 
-	if len(buf) == 3 {
-		if buf[0] == 27 && buf[1] == 91 && buf[2] == 65 {
-			e.key = KeyUp
-		} else if buf[0] == 27 && buf[1] == 91 && buf[2] == 66 {
-			e.key = KeyDown
+	// An escape followed by another key is how terminals send the key with alt held down
+	e, n := decodeChar(in[1:])
+	if n == 0 {
+		return e, 0
+	}
+	e.mod |= ModAlt
+	return e, n + 1
+}
+
+// decodeChar decodes a single (possibly multi-byte) character or control key.
+func decodeChar(in []byte) (Event, int) {
+	if in[0] < ' ' || in[0] == byte(KeyDelete) {
+		return Event{key: Key(in[0])}, 1
+	}
+
+	if !utf8.FullRune(in) {
+		return Event{}, 0
+	}
+	r, n := utf8.DecodeRune(in)
+	if r == utf8.RuneError {
+		return Event{key: KeyUnknown}, n
+	}
+	return Event{key: KeyChar, char: r}, n
+}
+
+// csiFinalKeys maps the final byte of a CSI (or SS3) sequence to the key that it represents.
+var csiFinalKeys = map[byte]Key{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+}
+
+// csiTildeKeys maps the first parameter of a CSI sequence ending in "~" to the key that it
+// represents.
+var csiTildeKeys = map[int]Key{
+	1: KeyHome,
+	3: KeyForwardDelete,
+	4: KeyEnd,
+	7: KeyHome,
+	8: KeyEnd,
+}
+
+// decodeCSI decodes a control sequence, which is made up of "ESC [", numeric parameters separated
+// by semicolons and a final byte. The second parameter, if present, encodes the modifier keys.
+func decodeCSI(in []byte) (Event, int) {
+	end := -1
+	for i := 2; i < len(in); i++ {
+		if in[i] >= 0x40 && in[i] <= 0x7e {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return Event{}, 0
+	}
+
+	var params []int
+	if end > 2 {
+		for _, p := range bytes.Split(in[2:end], []byte(";")) {
+			v, err := strconv.Atoi(string(p))
+			if err != nil {
+				return Event{key: KeyUnknown}, end + 1
+			}
+			params = append(params, v)
+		}
+	}
+
+	e := Event{key: KeyUnknown}
+	if len(params) >= 2 && params[1] > 1 {
+		e.mod = Modifier(params[1] - 1)
+	}
+
+	final := in[end]
+	if final == 'R' && len(params) == 2 {
+		// This is ambiguous with F3 and modifiers, which some terminals send as "ESC [ 1 ; n R",
+		// but F3 is not used
+		return Event{key: keyCursorPosition, row: params[0], col: params[1]}, end + 1
+	}
+	if final != '~' {
+		if k, ok := csiFinalKeys[final]; ok {
+			e.key = k
+		}
+		return e, end + 1
+	}
+
+	if len(params) == 0 {
+		return e, end + 1
+	}
+	if params[0] == 200 {
+		return decodePaste(in, end+1)
+	}
+	if k, ok := csiTildeKeys[params[0]]; ok {
+		e.key = k
+	}
+	return e, end + 1
+}
+
+// decodePaste decodes a bracketed paste, with the pasted text starting at the given offset.
+func decodePaste(in []byte, start int) (Event, int) {
+	i := bytes.Index(in[start:], []byte(pasteEnd))
+	if i < 0 {
+		return Event{}, 0
+	}
+	return Event{key: KeyPaste, text: string(in[start : start+i])}, start + i + len(pasteEnd)
+}
+
+// Size returns the width and height of the terminal.
+func (t *Tty) Size() (width, height int, err error) {
+	return term.GetSize(int(t.in.Fd()))
+}
+
+// Write writes the output to the terminal.
+func (t *Tty) Write(p []byte) (int, error) {
+	return t.out.Write(p)
+}
+
+// cursorPositionTimeout is how long CursorPosition waits for the terminal to report the cursor's
+// position.
+const cursorPositionTimeout = 100 * time.Millisecond
+
+// CursorPosition queries the terminal for the (one-based) row and column of the cursor, returning
+// an error if the terminal does not report it in time. Any key presses that are read in the
+// meantime are kept for GetKeyboardEvent and PollKeyboardEvent.
+func (t *Tty) CursorPosition() (row, col int, err error) {
+	fmt.Fprint(t, "\033[6n")
+
+	deadline := time.Now().Add(cursorPositionTimeout)
+	for {
+		var e Event
+		var ok bool
+		if e, t.pending, ok = takeCursorPosition(t.pending); ok {
+			return e.row, e.col, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return 0, 0, errors.New("the terminal did not report the cursor's position")
 		}
+		ready, err := waitForInput(t.in, remaining)
+		if err != nil {
+			return 0, 0, err
+		} else if !ready {
+			continue
+		}
+
+		if err := t.read(); err != nil {
+			return 0, 0, err
+		}
+	}
+}
+
+// takeCursorPosition finds the first cursor position report in the input, returning it along with
+// the rest of the input.
+func takeCursorPosition(in []byte) (Event, []byte, bool) {
+	for i := 0; i < len(in); {
+		e, n := decodeEvent(in[i:])
+		if n == 0 {
+			break
+		}
+		if e.key == keyCursorPosition {
+			return e, append(in[:i:i], in[i+n:]...), true
+		}
+		i += n
 	}
+	return Event{}, in, false
+}
 
-	return &e, nil
+// Resized determines whether the terminal has been resized since the last call. Resizes are
+// signalled on most platforms, but the size is compared as well so that they are also detected
+// elsewhere when the terminal is next polled.
+func (t *Tty) Resized() bool {
+	select {
+	case <-t.resize:
+	default:
+	}
+
+	width, height, err := t.Size()
+	if err != nil || (width == t.width && height == t.height) {
+		return false
+	}
+	t.width, t.height = width, height
+	return true
 }
 
 // Stop restores the current terminal to its previous state. It should be called after the caller
 // is done using the Tty.
 func (t *Tty) Stop() error {
-	return term.Restore(int(os.Stdin.Fd()), t.oldState)
+	signal.Stop(t.resize)
+	fmt.Fprint(t, disableBracketedPaste)
+	return term.Restore(int(t.in.Fd()), t.oldState)
 }
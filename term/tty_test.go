@@ -0,0 +1,134 @@
+package term
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	tests := []struct {
+		msg   string
+		input string
+		want  []Event
+	}{
+		{
+			msg:   "ASCII and multi-byte characters",
+			input: "aé😀",
+			want:  []Event{{key: KeyChar, char: 'a'}, {key: KeyChar, char: 'é'}, {key: KeyChar, char: '😀'}},
+		},
+		{
+			msg:   "Control keys",
+			input: "\x01\x03\t\r\x17\x7f",
+			want:  []Event{{key: KeyCtrlA}, {key: KeyCtrlC}, {key: KeyTab}, {key: KeyEnter}, {key: KeyCtrlW}, {key: KeyDelete}},
+		},
+		{
+			msg:   "Arrow keys in normal and application mode",
+			input: "\x1b[A\x1b[B\x1b[C\x1b[D\x1bOA\x1bOD",
+			want:  []Event{{key: KeyUp}, {key: KeyDown}, {key: KeyRight}, {key: KeyLeft}, {key: KeyUp}, {key: KeyLeft}},
+		},
+		{
+			msg:   "Home, end and delete",
+			input: "\x1b[H\x1b[F\x1b[1~\x1b[4~\x1b[3~",
+			want:  []Event{{key: KeyHome}, {key: KeyEnd}, {key: KeyHome}, {key: KeyEnd}, {key: KeyForwardDelete}},
+		},
+		{
+			msg:   "CSI with modifiers",
+			input: "\x1b[1;5D\x1b[1;3C\x1b[3;2~",
+			want:  []Event{{key: KeyLeft, mod: ModCtrl}, {key: KeyRight, mod: ModAlt}, {key: KeyForwardDelete, mod: ModShift}},
+		},
+		{
+			msg:   "Alt with characters and backspace",
+			input: "\x1bb\x1b\x7f\x1bé",
+			want:  []Event{{key: KeyChar, char: 'b', mod: ModAlt}, {key: KeyDelete, mod: ModAlt}, {key: KeyChar, char: 'é', mod: ModAlt}},
+		},
+		{
+			msg:   "Escape",
+			input: "\x1b\x1b[A\x1b",
+			want:  []Event{{key: KeyEscape}, {key: KeyUp}, {key: KeyEscape}},
+		},
+		{
+			msg:   "Bracketed paste",
+			input: pasteStart + "git log\x1b[A\n" + pasteEnd + "x",
+			want:  []Event{{key: KeyPaste, text: "git log\x1b[A\n"}, {key: KeyChar, char: 'x'}},
+		},
+		{
+			msg:   "Cursor position reports",
+			input: "\x1b[12;40R",
+			want:  []Event{{key: keyCursorPosition, row: 12, col: 40}},
+		},
+		{
+			msg:   "Unknown sequences",
+			input: "\x1b[99Z\x1b[42~",
+			want:  []Event{{key: KeyUnknown}, {key: KeyUnknown}},
+		},
+	}
+
+	for _, tt := range tests {
+		var got []Event
+		in := []byte(tt.input)
+		for len(in) > 0 {
+			e, n := decodeEvent(in)
+			if n == 0 {
+				t.Errorf("%s: unable to decode %q", tt.msg, in)
+				break
+			}
+			got = append(got, e)
+			in = in[n:]
+		}
+
+		if diff := cmp.Diff(got, tt.want, cmp.AllowUnexported(Event{})); diff != "" {
+			t.Errorf("%s: events diff (-got, +want):\n%s", tt.msg, diff)
+		}
+	}
+}
+
+func TestDecodeIncompleteEvent(t *testing.T) {
+	for _, input := range []string{"\xc3", "\x1b[1;5", "\x1bO", pasteStart + "git", "\x1b\xf0\x9f"} {
+		if _, n := decodeEvent([]byte(input)); n != 0 {
+			t.Errorf("Decoding the incomplete input %q consumed %d byte(s)", input, n)
+		}
+	}
+}
+
+func TestTakeCursorPosition(t *testing.T) {
+	tests := []struct {
+		msg      string
+		input    string
+		wantOK   bool
+		wantRow  int
+		wantCol  int
+		wantRest string
+	}{
+		{
+			msg:     "only a report",
+			input:   "\x1b[3;7R",
+			wantOK:  true,
+			wantRow: 3,
+			wantCol: 7,
+		},
+		{
+			msg:      "key presses around the report",
+			input:    "ab\x1b[A\x1b[3;7Rc\x1b[1;1R",
+			wantOK:   true,
+			wantRow:  3,
+			wantCol:  7,
+			wantRest: "ab\x1b[Ac\x1b[1;1R",
+		},
+		{
+			msg:      "incomplete report",
+			input:    "a\x1b[3;",
+			wantRest: "a\x1b[3;",
+		},
+	}
+
+	for _, tt := range tests {
+		e, rest, ok := takeCursorPosition([]byte(tt.input))
+		if ok != tt.wantOK || e.row != tt.wantRow || e.col != tt.wantCol {
+			t.Errorf("%s: got position %d;%d (found: %t), want %d;%d (found: %t)", tt.msg, e.row, e.col, ok, tt.wantRow, tt.wantCol, tt.wantOK)
+		}
+		if string(rest) != tt.wantRest {
+			t.Errorf("%s: got remaining input %q, want %q", tt.msg, rest, tt.wantRest)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+// Package vt100 emulates the screen of a VT100 terminal in memory, so that the output of terminal
+// interfaces can be tested. Only the escape sequences that speeddial uses are supported: cursor
+// movements (CUU, CUD, CUF, CUB and CUP), erasing (EL and ED), bold text (SGR), saving and
+// restoring the cursor, carriage returns and line feeds. Other sequences, including colors and
+// private modes, are parsed and ignored.
+package vt100
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// continuation fills the cell after a wide character.
+const continuation = -1
+
+// Cell is a single cell of the screen.
+type Cell struct {
+	Rune rune
+	Bold bool
+}
+
+// Screen is an in-memory terminal screen. It implements io.Writer, updating the screen as output
+// is written to it.
+type Screen struct {
+	width, height int
+	cells         [][]Cell
+	// The (zero-based) position of the cursor. The column is the width when a line has been filled,
+	// in which case the line only wraps once the next character is written.
+	row, col           int
+	savedRow, savedCol int
+	// Whether text that is written is bold.
+	bold bool
+	// Output that ends with an incomplete escape sequence or character.
+	pending []byte
+}
+
+// NewScreen creates a blank screen with the given size, with the cursor in the top left corner.
+func NewScreen(width, height int) *Screen {
+	s := &Screen{width: width, height: height}
+	s.cells = make([][]Cell, height)
+	for i := range s.cells {
+		s.cells[i] = blankLine(width)
+	}
+	return s
+}
+
+func blankLine(width int) []Cell {
+	line := make([]Cell, width)
+	for i := range line {
+		line[i] = Cell{Rune: ' '}
+	}
+	return line
+}
+
+// Size returns the width and height of the screen.
+func (s *Screen) Size() (width, height int) {
+	return s.width, s.height
+}
+
+// Cursor returns the (zero-based) row and column of the cursor.
+func (s *Screen) Cursor() (row, col int) {
+	return s.row, min(s.col, s.width-1)
+}
+
+// Resize changes the size of the screen, cutting off or padding lines as needed (without
+// rewrapping them) and keeping the cursor on the screen.
+func (s *Screen) Resize(width, height int) {
+	// Lines are removed from the top if the screen gets shorter, as terminals do
+	if shift := s.row - (height - 1); shift > 0 {
+		s.cells = s.cells[shift:]
+		s.row -= shift
+	}
+
+	cells := make([][]Cell, height)
+	for i := range cells {
+		cells[i] = blankLine(width)
+		if i < len(s.cells) {
+			copy(cells[i], s.cells[i])
+		}
+	}
+	s.cells = cells
+	s.width, s.height = width, height
+	s.col = min(s.col, width)
+}
+
+// Lines returns the text on each line of the screen, without trailing spaces.
+func (s *Screen) Lines() []string {
+	lines := make([]string, s.height)
+	for i, line := range s.cells {
+		var b strings.Builder
+		for _, c := range line {
+			if c.Rune != continuation {
+				b.WriteRune(c.Rune)
+			}
+		}
+		lines[i] = strings.TrimRight(b.String(), " ")
+	}
+	return lines
+}
+
+// Cell returns the cell at the given (zero-based) row and column.
+func (s *Screen) Cell(row, col int) Cell {
+	return s.cells[row][col]
+}
+
+// String returns the text on the screen, without trailing spaces or blank lines.
+func (s *Screen) String() string {
+	return strings.TrimRight(strings.Join(s.Lines(), "\n"), "\n")
+}
+
+// Write updates the screen with the output.
+func (s *Screen) Write(p []byte) (int, error) {
+	in := append(s.pending, p...)
+	for len(in) > 0 {
+		n := s.process(in)
+		if n == 0 {
+			break
+		}
+		in = in[n:]
+	}
+	s.pending = append([]byte(nil), in...)
+	return len(p), nil
+}
+
+// process handles the first character or escape sequence in the input, returning the number of
+// bytes that it took up, or zero if it is incomplete.
+func (s *Screen) process(in []byte) int {
+	switch in[0] {
+	case '\033':
+		return s.escape(in)
+	case '\r':
+		s.col = 0
+		return 1
+	case '\n':
+		s.lineFeed()
+		return 1
+	case '\b':
+		s.col = max(min(s.col, s.width-1)-1, 0)
+		return 1
+	case '\a':
+		return 1
+	}
+
+	if !utf8.FullRune(in) {
+		return 0
+	}
+	r, n := utf8.DecodeRune(in)
+	if r >= ' ' {
+		s.put(r)
+	}
+	return n
+}
+
+// put writes a character at the cursor, wrapping to the next line first if it does not fit.
+func (s *Screen) put(r rune) {
+	w := runewidth.RuneWidth(r)
+	if w == 0 {
+		return
+	}
+	if s.col+w > s.width {
+		s.col = 0
+		s.lineFeed()
+	}
+
+	s.cells[s.row][s.col] = Cell{Rune: r, Bold: s.bold}
+	if w == 2 {
+		s.cells[s.row][s.col+1] = Cell{Rune: continuation, Bold: s.bold}
+	}
+	s.col += w
+}
+
+// lineFeed moves the cursor down a line, scrolling the screen up if it is on the last line.
+func (s *Screen) lineFeed() {
+	if s.row < s.height-1 {
+		s.row++
+		return
+	}
+	s.cells = append(s.cells[1:], blankLine(s.width))
+}
+
+// escape handles an escape sequence, returning the number of bytes that it took up, or zero if it
+// is incomplete.
+func (s *Screen) escape(in []byte) int {
+	if len(in) < 2 {
+		return 0
+	}
+
+	switch in[1] {
+	case '[':
+		return s.csi(in)
+	case ']':
+		// Operating system commands (e.g. setting the clipboard) end with a bell or "ESC \"
+		if i := bytes.IndexByte(in, '\a'); i >= 0 {
+			return i + 1
+		} else if i := bytes.Index(in, []byte("\033\\")); i >= 0 {
+			return i + 2
+		}
+		return 0
+	case '7':
+		s.savedRow, s.savedCol = s.row, s.col
+	case '8':
+		s.row, s.col = s.savedRow, s.savedCol
+	}
+	return 2
+}
+
+// csi handles a control sequence, which is made up of "ESC [", optional parameters and a final
+// byte.
+func (s *Screen) csi(in []byte) int {
+	end := -1
+	for i := 2; i < len(in); i++ {
+		if in[i] >= 0x40 && in[i] <= 0x7e {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return 0
+	}
+
+	// Private sequences (e.g. "ESC [ ? 2004 h") are ignored
+	if end > 2 && in[2] == '?' {
+		return end + 1
+	}
+
+	var params []int
+	for _, p := range strings.Split(string(in[2:end]), ";") {
+		v, _ := strconv.Atoi(p)
+		params = append(params, v)
+	}
+	// param returns the i-th parameter, or the default if it is missing or zero.
+	param := func(i, def int) int {
+		if i < len(params) && params[i] > 0 {
+			return params[i]
+		}
+		return def
+	}
+
+	col := min(s.col, s.width-1)
+	switch in[end] {
+	case 'A':
+		s.row, s.col = max(s.row-param(0, 1), 0), col
+	case 'B':
+		s.row, s.col = min(s.row+param(0, 1), s.height-1), col
+	case 'C':
+		s.col = min(col+param(0, 1), s.width-1)
+	case 'D':
+		s.col = max(col-param(0, 1), 0)
+	case 'H':
+		s.row = min(param(0, 1), s.height) - 1
+		s.col = min(param(1, 1), s.width) - 1
+	case 'K':
+		s.eraseLine(param(0, 0))
+	case 'J':
+		s.eraseScreen(param(0, 0))
+	case 'm':
+		s.graphics(params)
+	}
+	return end + 1
+}
+
+// graphics handles a Select Graphic Rendition sequence, of which only bold text is tracked.
+func (s *Screen) graphics(params []int) {
+	for i := 0; i < len(params); i++ {
+		switch params[i] {
+		case 0, 22:
+			s.bold = false
+		case 1:
+			s.bold = true
+		case 38, 48, 58:
+			// Extended colors are followed by either "5;n" or "2;r;g;b"
+			if i+1 < len(params) && params[i+1] == 5 {
+				i += 2
+			} else if i+1 < len(params) && params[i+1] == 2 {
+				i += 4
+			}
+		}
+	}
+}
+
+// eraseLine erases from the cursor to the end of the line (0), from the start of the line to the
+// cursor (1) or the whole line (2).
+func (s *Screen) eraseLine(mode int) {
+	start, end := 0, s.width
+	switch mode {
+	case 0:
+		start = min(s.col, s.width)
+	case 1:
+		end = min(s.col+1, s.width)
+	}
+	for i := start; i < end; i++ {
+		s.cells[s.row][i] = Cell{Rune: ' '}
+	}
+}
+
+// eraseScreen erases from the cursor to the end of the screen (0), from the start of the screen to
+// the cursor (1) or the whole screen (2).
+func (s *Screen) eraseScreen(mode int) {
+	switch mode {
+	case 0:
+		s.eraseLine(0)
+		for i := s.row + 1; i < s.height; i++ {
+			s.cells[i] = blankLine(s.width)
+		}
+	case 1:
+		s.eraseLine(1)
+		for i := 0; i < s.row; i++ {
+			s.cells[i] = blankLine(s.width)
+		}
+	default:
+		for i := range s.cells {
+			s.cells[i] = blankLine(s.width)
+		}
+	}
+}
+
+func min(a, b int) int {
+	if a <= b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a >= b {
+		return a
+	}
+	return b
+}
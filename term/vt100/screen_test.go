@@ -0,0 +1,145 @@
+package vt100
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScreen(t *testing.T) {
+	tests := []struct {
+		msg        string
+		writes     []string
+		want       string
+		wantCursor [2]int
+	}{
+		{
+			msg:        "text with carriage returns and line feeds",
+			writes:     []string{"first\r\nsecond\n\rthird"},
+			want:       "first\nsecond\nthird",
+			wantCursor: [2]int{2, 5},
+		},
+		{
+			msg:        "line feeds without carriage returns keep the column",
+			writes:     []string{"ab\ncd"},
+			want:       "ab\n  cd",
+			wantCursor: [2]int{1, 4},
+		},
+		{
+			msg:        "wrapping",
+			writes:     []string{"0123456789", "ab"},
+			want:       "0123456789\nab",
+			wantCursor: [2]int{1, 2},
+		},
+		{
+			msg:        "a full line does not wrap until the next character",
+			writes:     []string{"0123456789\r\nab"},
+			want:       "0123456789\nab",
+			wantCursor: [2]int{1, 2},
+		},
+		{
+			msg:        "wide characters",
+			writes:     []string{"日本語のコマンド"},
+			want:       "日本語のコ\nマンド",
+			wantCursor: [2]int{1, 6},
+		},
+		{
+			msg:        "scrolling",
+			writes:     []string{"1\r\n2\r\n3\r\n4\r\n5"},
+			want:       "2\n3\n4\n5",
+			wantCursor: [2]int{3, 1},
+		},
+		{
+			msg:        "cursor movements",
+			writes:     []string{"abc\r\ndef\033[1A\033[2Cx\033[1B\033[3Dy\033[1;1Hz"},
+			want:       "zbc  x\ndefy",
+			wantCursor: [2]int{0, 1},
+		},
+		{
+			msg:        "movements stop at the edges of the screen",
+			writes:     []string{"\033[5A\033[20Cx\033[20Dy\033[9Bz"},
+			want:       "y        x\n\n\n z",
+			wantCursor: [2]int{3, 2},
+		},
+		{
+			msg:        "erasing lines",
+			writes:     []string{"abcdef\033[3D\033[K\r\nghijkl\033[3D\033[1K\r\nmnop\033[2K"},
+			want:       "abc\n    kl",
+			wantCursor: [2]int{2, 4},
+		},
+		{
+			msg:        "erasing the screen",
+			writes:     []string{"abc\r\ndef\r\nghi\033[1A\033[2D\033[0J"},
+			want:       "abc\nd",
+			wantCursor: [2]int{1, 1},
+		},
+		{
+			msg:        "saving and restoring the cursor",
+			writes:     []string{"ab\0337\r\ncd\0338x"},
+			want:       "abx\ncd",
+			wantCursor: [2]int{0, 3},
+		},
+		{
+			msg:        "colors and private modes are ignored",
+			writes:     []string{"\033[?2004h\033[1ma\033[38;5;12mb\033[0mc\033]52;c;eA==\a"},
+			want:       "abc",
+			wantCursor: [2]int{0, 3},
+		},
+		{
+			msg:        "sequences and characters split across writes",
+			writes:     []string{"a\033[", "1;3Hb\xc3", "\xa9"},
+			want:       "a bé",
+			wantCursor: [2]int{0, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.msg, func(t *testing.T) {
+			s := NewScreen(10, 4)
+			for _, w := range tt.writes {
+				if _, err := s.Write([]byte(w)); err != nil {
+					t.Fatalf("Unable to write %q: %v", w, err)
+				}
+			}
+
+			if diff := cmp.Diff(s.String(), tt.want); diff != "" {
+				t.Errorf("Screen diff (-got, +want):\n%s", diff)
+			}
+			if row, col := s.Cursor(); row != tt.wantCursor[0] || col != tt.wantCursor[1] {
+				t.Errorf("Got the cursor at %d,%d, want %d,%d", row, col, tt.wantCursor[0], tt.wantCursor[1])
+			}
+		})
+	}
+}
+
+func TestScreenResize(t *testing.T) {
+	s := NewScreen(10, 4)
+	s.Write([]byte("1\r\n2\r\n3\r\n0123456789"))
+
+	s.Resize(5, 2)
+	if diff := cmp.Diff(s.Lines(), []string{"3", "01234"}); diff != "" {
+		t.Errorf("Lines after shrinking diff (-got, +want):\n%s", diff)
+	}
+	if row, col := s.Cursor(); row != 1 || col != 4 {
+		t.Errorf("Got the cursor at %d,%d after shrinking, want 1,4", row, col)
+	}
+
+	s.Resize(6, 3)
+	if diff := cmp.Diff(s.Lines(), []string{"3", "01234", ""}); diff != "" {
+		t.Errorf("Lines after growing diff (-got, +want):\n%s", diff)
+	}
+}
+
+func TestScreenBold(t *testing.T) {
+	s := NewScreen(10, 2)
+	s.Write([]byte("a\033[1mb\033[38;5;1mc\033[22md\033[1;31me\033[mf"))
+
+	var got []bool
+	for col := 0; col < 6; col++ {
+		got = append(got, s.Cell(0, col).Bold)
+	}
+	if diff := cmp.Diff(got, []bool{false, true, true, false, true, false}); diff != "" {
+		t.Errorf("Bold cells diff (-got, +want):\n%s", diff)
+	}
+}